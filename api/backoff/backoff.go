@@ -0,0 +1,95 @@
+// Package backoff computes retry delays. Every retry loop goes through it so
+// the jitter policy (BACKOFF_JITTER) applies everywhere and goroutines that
+// failed together do not retry in lockstep.
+package backoff
+
+import (
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Jitter int32
+
+const (
+	// JitterNone uses the exponential delay as is.
+	JitterNone Jitter = iota
+	// JitterFull picks uniformly in [0, delay].
+	JitterFull
+	// JitterDecorrelated picks uniformly in [base, 3*previous delay], capped.
+	JitterDecorrelated
+)
+
+var current atomic.Int32
+
+func init() {
+	current.Store(int32(JitterFull))
+}
+
+// SetJitter sets the jitter policy used by Delay and Jittered.
+func SetJitter(j Jitter) {
+	current.Store(int32(j))
+}
+
+// ParseJitter maps none|full|decorrelated (case-insensitive) to a Jitter.
+func ParseJitter(s string) (Jitter, bool) {
+	switch strings.ToLower(s) {
+	case "none":
+		return JitterNone, true
+	case "full":
+		return JitterFull, true
+	case "decorrelated":
+		return JitterDecorrelated, true
+	}
+	return JitterFull, false
+}
+
+// Delay returns the wait before retry number attempt (1-based): base doubled
+// per attempt and capped at max, then jittered.
+func Delay(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := exponential(attempt, base, max)
+	switch Jitter(current.Load()) {
+	case JitterFull:
+		return between(0, d)
+	case JitterDecorrelated:
+		// The caller keeps no state, so the previous delay is taken to be
+		// the un-jittered one of the previous attempt.
+		prev := base
+		if attempt > 1 {
+			prev = exponential(attempt-1, base, max)
+		}
+		return between(base, min(max, 3*prev))
+	}
+	return d
+}
+
+// Jittered applies the jitter policy to a fixed delay d. It uses equal
+// jitter, uniformly in [d/2, d], whatever the policy other than none: a
+// fixed delay is a wait the caller needs, e.g. for a busy worker to free a
+// slot, so it must not collapse to zero.
+func Jittered(d time.Duration) time.Duration {
+	if Jitter(current.Load()) == JitterNone {
+		return d
+	}
+	return between(d/2, d)
+}
+
+func exponential(attempt int, base, max time.Duration) time.Duration {
+	d := base << (attempt - 1)
+	if d <= 0 || d > max {
+		d = max
+	}
+	return d
+}
+
+// between returns a uniformly random duration in [lo, hi].
+func between(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(rand.Int63n(int64(hi-lo)+1))
+}
@@ -0,0 +1,75 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	defer SetJitter(JitterFull)
+	const base, max = 10 * time.Millisecond, 200 * time.Millisecond
+	tests := []struct {
+		jitter  Jitter
+		attempt int
+		lo, hi  time.Duration
+	}{
+		{JitterNone, 1, base, base},
+		{JitterNone, 3, 40 * time.Millisecond, 40 * time.Millisecond},
+		{JitterNone, 10, max, max},
+		{JitterFull, 1, 0, base},
+		{JitterFull, 3, 0, 40 * time.Millisecond},
+		{JitterFull, 10, 0, max},
+		{JitterDecorrelated, 1, base, 3 * base},
+		{JitterDecorrelated, 3, base, 60 * time.Millisecond},
+		{JitterDecorrelated, 10, base, max},
+	}
+	for _, tt := range tests {
+		SetJitter(tt.jitter)
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 200; i++ {
+			d := Delay(tt.attempt, base, max)
+			if d < tt.lo || d > tt.hi {
+				t.Fatalf("jitter %d attempt %d: Delay = %s, want in [%s, %s]", tt.jitter, tt.attempt, d, tt.lo, tt.hi)
+			}
+			seen[d] = true
+		}
+		if tt.lo != tt.hi && len(seen) < 2 {
+			t.Errorf("jitter %d attempt %d: Delay did not vary over 200 calls", tt.jitter, tt.attempt)
+		}
+	}
+}
+
+func TestJittered(t *testing.T) {
+	defer SetJitter(JitterFull)
+	const d = 10 * time.Millisecond
+	for _, j := range []Jitter{JitterFull, JitterDecorrelated} {
+		SetJitter(j)
+		for i := 0; i < 200; i++ {
+			if got := Jittered(d); got < d/2 || got > d {
+				t.Fatalf("jitter %d: Jittered(%s) = %s, want in [%s, %s]", j, d, got, d/2, d)
+			}
+		}
+	}
+	SetJitter(JitterNone)
+	if got := Jittered(d); got != d {
+		t.Errorf("no jitter: Jittered(%s) = %s", d, got)
+	}
+}
+
+func TestParseJitter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Jitter
+		ok   bool
+	}{
+		{"none", JitterNone, true},
+		{"FULL", JitterFull, true},
+		{"decorrelated", JitterDecorrelated, true},
+		{"bogus", JitterFull, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseJitter(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseJitter(%q) = %d, %t, want %d, %t", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
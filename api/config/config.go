@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/logging"
 )
 
 // Configuration constants
@@ -17,20 +22,395 @@ const (
 	QueueSize           = 10000
 	NumWorkers          = 100
 	RingBufferSize      = 50000
+	DBPingInterval      = 2 * time.Second
+	ReprocessBaseDelay  = 100 * time.Millisecond
+	ReprocessMaxDelay   = 5 * time.Second
+
+	// Postgres allows at most 65535 bind parameters per statement and the
+	// PaymentLogger uses three per row.
+	maxLoggerBatchSize = 65535 / 3
+
+	PartitionMaintenanceInterval = time.Hour
+	PaymentPruneInterval         = time.Minute
 )
 
 // Global Variables
 var (
+	// Processor URLs as configured at startup; ProcessorURL returns the ones
+	// currently in use.
 	DefaultProcessorURL  string
 	FallbackProcessorURL string
 	WorkerURL            string
 	PostgresDSN          string
 	PostgresPool         *pgxpool.Pool
+
+	// Pool for heavy read-only queries such as the summary and the
+	// time-series endpoints, on a read replica when POSTGRES_READ_DSN is set
+	// and PostgresPool otherwise. Replica reads may lag the primary.
+	PostgresReadPool *pgxpool.Pool
+
+	// Workers the gateway forwards to (WORKER_URLS, comma-separated),
+	// defaulting to WorkerURL alone. WorkerBalance (WORKER_BALANCE) picks
+	// among them: "round-robin" or "least-outstanding" (fewest forwards in
+	// flight). A worker whose forward failed is skipped for
+	// WorkerFailureCooldown (WORKER_FAILURE_COOLDOWN_MS) unless all are.
+	WorkerURLs            []string
+	WorkerBalance         string
+	WorkerFailureCooldown time.Duration
+
+	// Gateway→worker HTTP connection pool (WORKER_MAX_IDLE_CONNS,
+	// WORKER_MAX_IDLE_CONNS_PER_HOST, WORKER_IDLE_CONN_TIMEOUT_S). Every
+	// forwarder targets the same worker host, so the per-host cap defaults to
+	// NumWorkers to keep one idle connection per forwarder.
+	WorkerMaxIdleConns        int
+	WorkerMaxIdleConnsPerHost int
+	WorkerIdleConnTimeout     time.Duration
+
+	// Connection setup limits of the gateway→worker and worker→processor
+	// transports (DIAL_TIMEOUT_MS, TLS_HANDSHAKE_TIMEOUT_MS), separate from
+	// the request timeouts, so an unreachable host fails fast. 0 disables.
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// Limit on the worker's per-payment queries, the duplicate check and the
+	// outcome write (DB_QUERY_TIMEOUT_MS, 0 = unbounded), so a stalled
+	// database cannot pile up processing goroutines.
+	DBQueryTimeout time.Duration
+
+	// Times the worker re-runs its duplicate check or outcome write after a
+	// serialization failure (SQLSTATE 40001) under raised isolation
+	// (SERIALIZATION_RETRIES, 0 disables).
+	SerializationRetries int
+
+	// Create the payments table and apply the embedded migrations at startup
+	// (DB_MIGRATE, default true). Turn it off when the schema is managed
+	// out of band.
+	RunMigrations bool
+
+	// Daily partitioning of the payments table (PARTITION_BY_DAY).
+	PartitionByDay         bool
+	PartitionRetentionDays int
+
+	// Delete processed and failed payments older than this
+	// (PAYMENT_RETENTION_S, 0 keeps everything), PaymentPruneBatch rows per
+	// statement (PAYMENT_PRUNE_BATCH). Pruned processed payments are folded
+	// into payments_pruned_totals so the summary keeps counting them, but a
+	// pruned correlationId is no longer recognised as a duplicate; pair it
+	// with MAX_PAYMENT_AGE_S.
+	PaymentRetention  time.Duration
+	PaymentPruneBatch int
+
+	// Timeout of one processor call (PROCESSOR_ATTEMPT_TIMEOUT_MS, default
+	// PaymentTimeout) and of all calls for one processing pass, retries and
+	// fallback included (PROCESSOR_TOTAL_TIMEOUT_MS, 0 = uncapped).
+	ProcessorAttemptTimeout time.Duration
+	ProcessorTotalTimeout   time.Duration
+
+	// Processor retries (PROCESSOR_RETRIES, default 0), capped by a
+	// per-processor retry budget (RETRY_BUDGET_RATIO, RETRY_BUDGET_TOKENS).
+	// The processor POST is not idempotent: a retry is only safe after an
+	// error raised before the request was sent, such as a refused
+	// connection. After a timeout the processor may have charged the
+	// payment, and the retry double-charges it or gets a 422.
+	ProcessorRetries  int
+	RetryBudgetRatio  float64
+	RetryBudgetTokens float64
+
+	// Global token bucket on outbound processor calls (PROCESSOR_RATE_LIMIT
+	// calls per second, 0 disables; PROCESSOR_RATE_BURST). A call that would
+	// wait longer than PROCESSOR_RATE_MAX_WAIT_MS fails and the payment goes
+	// through the usual re-process path.
+	ProcessorRateLimit   float64
+	ProcessorRateBurst   int
+	ProcessorRateMaxWait time.Duration
+
+	// Idle connections opened to each processor at worker startup
+	// (PROCESSOR_WARMUP_CONNS, 0 disables).
+	WarmupConns int
+
+	// How often processor hostnames are re-resolved to detect address
+	// changes (PROCESSOR_DNS_TTL_S, 0 disables).
+	ProcessorDNSTTL time.Duration
+
+	// Processor endpoint reporting its own totals, used by /verify
+	// (PROCESSOR_SUMMARY_PATH), and the X-Rinha-Token it requires
+	// (PROCESSOR_ADMIN_TOKEN).
+	ProcessorSummaryPath string
+	ProcessorAdminToken  string
+
+	// User-Agent of outbound requests to processors and workers
+	// (OUTBOUND_USER_AGENT, default "rinha-backend-golang"), and static headers
+	// added to them (OUTBOUND_HEADERS, e.g. "X-Client=team-42,X-Env=prod").
+	OutboundUserAgent string
+	OutboundHeaders   map[string]string
+
+	// Payments slower than this end to end are logged (SLOW_PAYMENT_MS, 0 disables).
+	SlowPaymentThreshold time.Duration
+
+	// In-memory bloom filter in front of the duplicate check (DEDUP_BLOOM_BITS,
+	// 0 disables). It only sees the payments this worker recorded, so its
+	// "not seen" is only trustworthy when no other worker records payments:
+	// it must be confirmed with DEDUP_BLOOM_SOLE_WRITER and is refused when
+	// WORKER_URLS lists several workers.
+	DedupBloomBits   int
+	DedupBloomHashes int
+
+	// Record processed payments with a single INSERT ... ON CONFLICT DO
+	// UPDATE ... RETURNING (DEDUP_UPSERT) instead of an UPDATE followed by an
+	// INSERT, so of two passes recording the same payment exactly one counts
+	// it. Needs correlation_id to be unique on its own, so it is ignored
+	// with PARTITION_BY_DAY.
+	DedupUpsert bool
+
+	// Token required by admin endpoints in the X-Admin-Token header (ADMIN_TOKEN).
+	// Admin endpoints are disabled when it is empty.
+	AdminToken string
+
+	// Budget for one HTTP handler call before it is answered with 503
+	// (HANDLER_TIMEOUT_MS, 0 disables), overridable per route pattern with
+	// HANDLER_TIMEOUTS (e.g. "/payments-summary=2000,/maintenance/vacuum=0").
+	DefaultHandlerTimeout time.Duration
+	routeTimeouts         map[string]time.Duration
+
+	// Time a /payments client has to send its body before getting a 408
+	// (PAYMENTS_BODY_TIMEOUT_MS, 0 disables), much shorter than the handler
+	// budget since a payment body is a few dozen bytes.
+	PaymentsBodyTimeout time.Duration
+
+	// Decimals of the totalAmount fields of summaries (SUMMARY_DECIMALS,
+	// default 2 like the processors' own summaries; -1 prints the shortest
+	// exact form of the float).
+	SummaryDecimals int
+
+	// Limits on the summary and time-series reads of the worker: a from/to
+	// range may span at most SummaryMaxRange (SUMMARY_MAX_RANGE_DAYS, 0 =
+	// unlimited) and each query is cancelled after SummaryQueryTimeout
+	// (SUMMARY_QUERY_TIMEOUT_MS, 0 = unbounded), so a request over a large
+	// table cannot keep the database busy indefinitely.
+	SummaryMaxRange     time.Duration
+	SummaryQueryTimeout time.Duration
+
+	// Accepted correlationIds: at most CORRELATION_ID_MAX_LEN bytes, in the
+	// CORRELATION_ID_CHARSET "uuid" (canonical UUIDs, the default) or
+	// "token" (letters, digits, '-', '_' and '.').
+	CorrelationIDMaxLen  int
+	CorrelationIDCharset string
+
+	// Reject payments whose timestamp is older than this (MAX_PAYMENT_AGE_S,
+	// 0 disables), so replayed payment files are not charged again.
+	MaxPaymentAge time.Duration
+
+	// What the gateway drops when its queue is full (QUEUE_DROP_POLICY):
+	// "newest" refuses the incoming payment, "oldest" evicts the oldest
+	// queued one to make room.
+	QueueDropOldest bool
+
+	// Order in which the gateway forwards queued payments (QUEUE_ORDER):
+	// "fifo" in arrival order, "deadline" closest client deadline first, so
+	// more payments reach a worker before their deadline under a backlog.
+	QueueOrder string
+
+	// Reject /payments bodies not sent as application/json (STRICT_CONTENT_TYPE).
+	StrictContentType bool
+
+	// Keep every logged /payments body byte for byte in raw_payloads
+	// (STORE_RAW_PAYLOADS), duplicates included, for MODE=replay to re-post
+	// to REPLAY_TARGET_URL (default http://localhost:8080). Off by default:
+	// it roughly doubles the rows written per payment.
+	StoreRawPayloads bool
+	ReplayTargetURL  string
+
+	// Truncate the payment tables when the worker starts, before serving,
+	// for clean benchmark runs (PURGE_ON_START). As a guard against wiping a
+	// real database, it only takes effect when PURGE_ON_START_CONFIRM names
+	// the database POSTGRES_DSN points at.
+	PurgeOnStart bool
+
+	// Treat a 200 health response with an unparseable body as healthy but
+	// degraded instead of unhealthy (HEALTH_TOLERATE_MALFORMED).
+	HealthTolerateMalformed bool
+
+	// Health polls answering slower than this mark an up processor degraded
+	// (HEALTH_DEGRADED_MS, 0 disables): still used, but after a processor
+	// that is not.
+	HealthDegradedLatency time.Duration
+
+	// Register test-only endpoints such as /sim/processor (SIM_ENDPOINTS).
+	// Off by default; never enable in production.
+	EnableSimEndpoints bool
+
+	// Skip health polling and treat every processor as healthy (DISABLE_HEALTH_CHECKS).
+	DisableHealthChecks bool
+
+	// How long worker startup waits for the first processor health poll
+	// (HEALTH_STARTUP_GRACE_MS, 0 = do not wait). /readyz reports 503 until
+	// that poll is done either way.
+	HealthStartupGrace time.Duration
+
+	// How long routing reuses one health reading (HEALTH_CACHE_MS, 0 disables).
+	HealthCacheTTL time.Duration
+
+	// Elect one worker through a Postgres lease to poll processor health and
+	// share the readings with the others (HEALTH_LEADER_ELECTION). A leader
+	// that stops renewing is replaced once its lease expires (HEALTH_LEADER_TTL_MS).
+	HealthLeaderElection bool
+	HealthLeaderTTL      time.Duration
+
+	// Cap on concurrent processPayment executions in the worker (MAX_INFLIGHT, 0 = unlimited).
+	MaxInflight int
+
+	// How long Idempotency-Key responses are remembered (IDEMPOTENCY_TTL_S, 0 disables).
+	IdempotencyTTL time.Duration
+
+	// Outbound JSON field renames per processor name, from
+	// DEFAULT_PROCESSOR_FIELD_MAP / FALLBACK_PROCESSOR_FIELD_MAP
+	// (e.g. "correlationId=correlation_id,amount=value").
+	ProcessorFieldMaps map[string]map[string]string
+
+	// Full health-check URL per processor, from DEFAULT_HEALTH_URL /
+	// FALLBACK_HEALTH_URL, for deployments serving health on another host or
+	// port. Empty means <processor URL>/payments/service-health.
+	ProcessorHealthURLs map[string]string
+
+	// HTTP method for each processor's health endpoint, from
+	// DEFAULT_PROCESSOR_HEALTH_METHOD / FALLBACK_PROCESSOR_HEALTH_METHOD (default GET).
+	ProcessorHealthMethods map[string]string
+
+	// Status codes counted as an accepted payment per processor, from
+	// DEFAULT_PROCESSOR_SUCCESS_CODES / FALLBACK_PROCESSOR_SUCCESS_CODES
+	// (e.g. "200,201,202", default 200).
+	ProcessorSuccessCodes map[string][]int
+
+	// HMAC key per processor for the X-Signature header on POST /payments,
+	// from DEFAULT_PROCESSOR_HMAC_SECRET / FALLBACK_PROCESSOR_HMAC_SECRET
+	// (unsigned when empty), with the hash from DEFAULT_PROCESSOR_HMAC_ALGO /
+	// FALLBACK_PROCESSOR_HMAC_ALGO: sha256 (default), sha1 or sha512.
+	ProcessorHMACSecrets map[string]string
+	ProcessorHMACAlgos   map[string]string
+
+	// Payments below this amount may go to the fallback first when it
+	// reports a lower minResponseTime than the default (CHEAP_ROUTING_THRESHOLD,
+	// 0 disables); larger ones always start on the cheaper default.
+	CheapRoutingThreshold float64
+
+	// Fee tiers per processor, from FEE_SCHEDULE or FEE_SCHEDULE_FILE (JSON,
+	// see loadFeeSchedules). When both processors have one, each payment
+	// starts on the processor charging it less.
+	ProcessorFeeSchedules map[string][]FeeTier
+
+	// Share of payments started on each processor while both are healthy,
+	// from ROUTING_WEIGHTS (e.g. "default=80,fallback=20"). When set it
+	// replaces priority, latency and fee based ordering.
+	ProcessorWeights map[string]int
+
+	// Soft deadline after which a still-pending default call is hedged with a
+	// parallel fallback call (HEDGE_AFTER_MS, 0 disables).
+	HedgeAfter time.Duration
+
+	// How the gateway hands payments to workers (FORWARD_MODE): "push" POSTs
+	// each payment to the worker, "pull" writes it to the payment_queue table
+	// for workers to claim in batches of PullBatchSize.
+	ForwardMode   string
+	PullBatchSize int
+
+	// What the gateway serves on PORT (PROTOCOL): "http" for POST /payments,
+	// "grpc" for the paymentspb.Payments service.
+	Protocol string
+
+	// Listen address for the pprof admin server (PPROF_ADDR, e.g. ":6060").
+	// Profiling is off when empty.
+	PprofAddr string
+
+	// PaymentLogger batching (LOGGER_BATCH_SIZE rows, LOGGER_FLUSH_MS), with
+	// up to LOGGER_FLUSH_WORKERS batches being written at once.
+	LoggerBatchSize     int
+	LoggerFlushInterval time.Duration
+	LoggerFlushWorkers  int
+
+	// Stop charging payments after this many consecutive failures to record
+	// a processed one (INSERT_FAILURE_LIMIT, 0 disables), until a write
+	// succeeds again: charging without recording breaks the summary.
+	InsertFailureLimit int
+
+	// Answer /payments only after the payment row is committed, instead of
+	// logging it asynchronously (DURABLE_ACCEPT).
+	DurableAccept bool
+
+	// Forward each payment to a worker from the /payments handler instead of
+	// queueing it (SYNC_FORWARD), so success means a worker accepted it and
+	// an unreachable worker is answered with 502. Slower than the default
+	// asynchronous queue: the client waits for the forward.
+	SyncForward bool
+
+	// Write processed payments from the worker in batches, sized and timed
+	// like the PaymentLogger's, instead of one statement per payment
+	// (WORKER_BATCH_INSERTS).
+	WorkerBatchInserts bool
+
+	// Record payments as processor "dry-run" without calling any processor (DRY_RUN).
+	DryRun bool
+
+	// Events a /payments/stream subscriber may lag behind before it is
+	// evicted (STREAM_BUFFER_SIZE).
+	StreamBufferSize int
+
+	// Interval between rows written to summary_snapshots (SUMMARY_SNAPSHOT_S, 0 disables).
+	SummarySnapshotInterval time.Duration
+
+	// Process payments sharing an ordering key one at a time, in submission
+	// order (ORDERED_PROCESSING). The key is the X-Partition-Key header or,
+	// without it, the first ORDERING_PREFIX_LEN bytes of the correlationId
+	// (0 = header only). Not available in pull mode.
+	OrderedProcessing bool
+	OrderingPrefixLen int
+
+	// How long a stopping gateway drains its queue, and a stopping worker
+	// waits for in-flight payments before saving the unfinished ones to
+	// payment_outbox (SHUTDOWN_GRACE_MS).
+	ShutdownGrace time.Duration
+
+	// Processing passes (each trying every healthy processor) before a
+	// payment is dead-lettered (MAX_PROCESS_ATTEMPTS).
+	MaxProcessAttempts int
+
+	// What to assume about a processor whose health is unknown, i.e. before
+	// the first poll or when the poll itself failed (HEALTH_FAILURE_POLICY):
+	// "closed", the default, assumes unhealthy; "open" assumes healthy.
+	HealthFailOpen bool
+
+	// Per-processor circuit breaker (BREAKER_FAILURES, 0 disables): that many
+	// consecutive processor calls timing out or failing take the processor
+	// out of routing for BreakerCooldown (BREAKER_COOLDOWN_MS).
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	// Startup preflight: whether to probe the processors' reachability
+	// (PREFLIGHT_CHECK_PROCESSORS), and how long to wait for a database that
+	// is still starting (PREFLIGHT_DB_WAIT_MS, default 10s).
+	PreflightCheckProcessors bool
+	PreflightDBWait          time.Duration
 )
 
 func Init() {
+	// Set the level first so the rest of Init logs at the configured level.
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		level, ok := logging.ParseLevel(v)
+		if !ok {
+			log.Printf("Invalid LOG_LEVEL=%q, using debug", v)
+		}
+		logging.SetLevel(level)
+	}
+	logging.SetSampleRate(envInt("LOG_SAMPLE_RATE", 0))
+	if v := os.Getenv("BACKOFF_JITTER"); v != "" {
+		jitter, ok := backoff.ParseJitter(v)
+		if !ok {
+			logging.Warnf("Invalid BACKOFF_JITTER=%q, using full", v)
+		}
+		backoff.SetJitter(jitter)
+	}
 	DefaultProcessorURL = os.Getenv("DEFAULT_PROCESSOR_URL")
 	FallbackProcessorURL = os.Getenv("FALLBACK_PROCESSOR_URL")
+	SetProcessorURLs(map[string]string{"default": DefaultProcessorURL, "fallback": FallbackProcessorURL})
 	workerHost := os.Getenv("WORKER_HOST")
 	if workerHost == "" {
 		workerHost = "worker"
@@ -40,48 +420,536 @@ func Init() {
 		workerPort = "8081"
 	}
 	WorkerURL = fmt.Sprintf("http://%s:%s", workerHost, workerPort)
+	WorkerURLs = envList("WORKER_URLS")
+	if len(WorkerURLs) == 0 {
+		WorkerURLs = []string{WorkerURL}
+	}
+	switch WorkerBalance = os.Getenv("WORKER_BALANCE"); WorkerBalance {
+	case "":
+		WorkerBalance = "round-robin"
+	case "round-robin", "least-outstanding":
+	default:
+		logging.Warnf("Invalid WORKER_BALANCE=%q, using round-robin", WorkerBalance)
+		WorkerBalance = "round-robin"
+	}
+	WorkerFailureCooldown = time.Duration(envInt("WORKER_FAILURE_COOLDOWN_MS", 1000)) * time.Millisecond
+	WorkerMaxIdleConns = envInt("WORKER_MAX_IDLE_CONNS", 2*NumWorkers)
+	WorkerMaxIdleConnsPerHost = envInt("WORKER_MAX_IDLE_CONNS_PER_HOST", NumWorkers)
+	WorkerIdleConnTimeout = time.Duration(envInt("WORKER_IDLE_CONN_TIMEOUT_S", 60)) * time.Second
+	DialTimeout = time.Duration(envInt("DIAL_TIMEOUT_MS", 500)) * time.Millisecond
+	TLSHandshakeTimeout = time.Duration(envInt("TLS_HANDSHAKE_TIMEOUT_MS", 1000)) * time.Millisecond
+	DBQueryTimeout = time.Duration(envInt("DB_QUERY_TIMEOUT_MS", 2000)) * time.Millisecond
+	SerializationRetries = envInt("SERIALIZATION_RETRIES", 3)
 
 	PostgresDSN = os.Getenv("POSTGRES_DSN")
+	RunMigrations = envBool("DB_MIGRATE", true)
+	PartitionByDay = envBool("PARTITION_BY_DAY", false)
+	PartitionRetentionDays = envInt("PARTITION_RETENTION_DAYS", 0)
+	PaymentRetention = time.Duration(envInt("PAYMENT_RETENTION_S", 0)) * time.Second
+	PaymentPruneBatch = envInt("PAYMENT_PRUNE_BATCH", 1000)
+	if PaymentPruneBatch <= 0 {
+		logging.Warnf("PAYMENT_PRUNE_BATCH must be positive, using 1000")
+		PaymentPruneBatch = 1000
+	}
+	ProcessorAttemptTimeout = time.Duration(envInt("PROCESSOR_ATTEMPT_TIMEOUT_MS", int(PaymentTimeout/time.Millisecond))) * time.Millisecond
+	ProcessorTotalTimeout = time.Duration(envInt("PROCESSOR_TOTAL_TIMEOUT_MS", 0)) * time.Millisecond
+	ProcessorRetries = envInt("PROCESSOR_RETRIES", 0)
+	RetryBudgetRatio = envFloat("RETRY_BUDGET_RATIO", 0.1)
+	RetryBudgetTokens = envFloat("RETRY_BUDGET_TOKENS", 10)
+	ProcessorRateLimit = envFloat("PROCESSOR_RATE_LIMIT", 0)
+	ProcessorRateBurst = envInt("PROCESSOR_RATE_BURST", 1)
+	ProcessorRateMaxWait = time.Duration(envInt("PROCESSOR_RATE_MAX_WAIT_MS", 1000)) * time.Millisecond
+	WarmupConns = envInt("PROCESSOR_WARMUP_CONNS", 0)
+	ProcessorDNSTTL = time.Duration(envInt("PROCESSOR_DNS_TTL_S", 30)) * time.Second
+	ProcessorSummaryPath = os.Getenv("PROCESSOR_SUMMARY_PATH")
+	if ProcessorSummaryPath == "" {
+		ProcessorSummaryPath = "/admin/payments-summary"
+	}
+	ProcessorAdminToken = os.Getenv("PROCESSOR_ADMIN_TOKEN")
+	if ProcessorAdminToken == "" {
+		// The token the Rinha payment processors ship with.
+		ProcessorAdminToken = "123"
+	}
+	OutboundUserAgent = os.Getenv("OUTBOUND_USER_AGENT")
+	if OutboundUserAgent == "" {
+		OutboundUserAgent = "rinha-backend-golang"
+	}
+	OutboundHeaders = envPairs("OUTBOUND_HEADERS")
+	SlowPaymentThreshold = time.Duration(envInt("SLOW_PAYMENT_MS", 0)) * time.Millisecond
+	DedupBloomBits = envInt("DEDUP_BLOOM_BITS", 0)
+	DedupBloomHashes = envInt("DEDUP_BLOOM_HASHES", 4)
+	if DedupBloomBits > 0 {
+		if len(WorkerURLs) > 1 {
+			logging.Warnf("DEDUP_BLOOM_BITS is not supported with several WORKER_URLS, another worker's payments would look new; ignoring it")
+			DedupBloomBits = 0
+		} else if !envBool("DEDUP_BLOOM_SOLE_WRITER", false) {
+			logging.Warnf("DEDUP_BLOOM_BITS ignored: set DEDUP_BLOOM_SOLE_WRITER=true to confirm this is the only worker recording payments")
+			DedupBloomBits = 0
+		}
+	}
+	DedupUpsert = envBool("DEDUP_UPSERT", false)
+	if DedupUpsert && PartitionByDay {
+		logging.Warnf("DEDUP_UPSERT is not supported with PARTITION_BY_DAY, ignoring it")
+		DedupUpsert = false
+	}
+	AdminToken = os.Getenv("ADMIN_TOKEN")
+	DefaultHandlerTimeout = time.Duration(envInt("HANDLER_TIMEOUT_MS", 10000)) * time.Millisecond
+	// VACUUM on a large table easily outlasts the default budget.
+	routeTimeouts = map[string]time.Duration{"/maintenance/vacuum": 0}
+	for route, v := range envPairs("HANDLER_TIMEOUTS") {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			logging.Warnf("Invalid timeout %q for %s in HANDLER_TIMEOUTS, ignoring", v, route)
+			continue
+		}
+		routeTimeouts[route] = time.Duration(ms) * time.Millisecond
+	}
+	PaymentsBodyTimeout = time.Duration(envInt("PAYMENTS_BODY_TIMEOUT_MS", 1000)) * time.Millisecond
+	SummaryDecimals = envInt("SUMMARY_DECIMALS", 2)
+	if SummaryDecimals < -1 {
+		logging.Warnf("Invalid SUMMARY_DECIMALS=%d, using 2", SummaryDecimals)
+		SummaryDecimals = 2
+	}
+	SummaryMaxRange = time.Duration(envInt("SUMMARY_MAX_RANGE_DAYS", 0)) * 24 * time.Hour
+	SummaryQueryTimeout = time.Duration(envInt("SUMMARY_QUERY_TIMEOUT_MS", 5000)) * time.Millisecond
+	CorrelationIDMaxLen = envInt("CORRELATION_ID_MAX_LEN", 36)
+	switch CorrelationIDCharset = os.Getenv("CORRELATION_ID_CHARSET"); CorrelationIDCharset {
+	case "uuid", "token":
+	case "":
+		CorrelationIDCharset = "uuid"
+	default:
+		logging.Warnf("Invalid CORRELATION_ID_CHARSET=%q, using uuid", CorrelationIDCharset)
+		CorrelationIDCharset = "uuid"
+	}
+	MaxPaymentAge = time.Duration(envInt("MAX_PAYMENT_AGE_S", 0)) * time.Second
+	switch QueueOrder = os.Getenv("QUEUE_ORDER"); QueueOrder {
+	case "":
+		QueueOrder = "fifo"
+	case "fifo", "deadline":
+	default:
+		logging.Warnf("Invalid QUEUE_ORDER=%q, using fifo", QueueOrder)
+		QueueOrder = "fifo"
+	}
+	switch policy := os.Getenv("QUEUE_DROP_POLICY"); policy {
+	case "", "newest":
+		QueueDropOldest = false
+	case "oldest":
+		QueueDropOldest = true
+	default:
+		logging.Warnf("Invalid QUEUE_DROP_POLICY=%q, using newest", policy)
+	}
+	StrictContentType = envBool("STRICT_CONTENT_TYPE", false)
+	StoreRawPayloads = envBool("STORE_RAW_PAYLOADS", false)
+	ReplayTargetURL = os.Getenv("REPLAY_TARGET_URL")
+	if ReplayTargetURL == "" {
+		ReplayTargetURL = "http://localhost:8080"
+	}
+	HealthTolerateMalformed = envBool("HEALTH_TOLERATE_MALFORMED", false)
+	HealthDegradedLatency = time.Duration(envInt("HEALTH_DEGRADED_MS", 0)) * time.Millisecond
+	EnableSimEndpoints = envBool("SIM_ENDPOINTS", false)
+	DisableHealthChecks = envBool("DISABLE_HEALTH_CHECKS", false)
+	HealthStartupGrace = time.Duration(envInt("HEALTH_STARTUP_GRACE_MS", 3000)) * time.Millisecond
+	HealthCacheTTL = time.Duration(envInt("HEALTH_CACHE_MS", 0)) * time.Millisecond
+	HealthLeaderElection = envBool("HEALTH_LEADER_ELECTION", false)
+	HealthLeaderTTL = time.Duration(envInt("HEALTH_LEADER_TTL_MS", int(3*HealthCheckInterval/time.Millisecond))) * time.Millisecond
+	MaxInflight = envInt("MAX_INFLIGHT", 0)
+	IdempotencyTTL = time.Duration(envInt("IDEMPOTENCY_TTL_S", 3600)) * time.Second
+	ForwardMode = os.Getenv("FORWARD_MODE")
+	if ForwardMode == "" {
+		ForwardMode = "push"
+	}
+	PullBatchSize = envInt("PULL_BATCH_SIZE", 50)
+	switch Protocol = os.Getenv("PROTOCOL"); Protocol {
+	case "":
+		Protocol = "http"
+	case "http", "grpc":
+	default:
+		logging.Warnf("Invalid PROTOCOL=%q, using http", Protocol)
+		Protocol = "http"
+	}
+	PprofAddr = os.Getenv("PPROF_ADDR")
+	DurableAccept = envBool("DURABLE_ACCEPT", false)
+	InsertFailureLimit = envInt("INSERT_FAILURE_LIMIT", 0)
+	SyncForward = envBool("SYNC_FORWARD", false)
+	WorkerBatchInserts = envBool("WORKER_BATCH_INSERTS", false)
+	DryRun = envBool("DRY_RUN", false)
+	BreakerFailures = envInt("BREAKER_FAILURES", 0)
+	BreakerCooldown = time.Duration(envInt("BREAKER_COOLDOWN_MS", 1000)) * time.Millisecond
+	PreflightCheckProcessors = envBool("PREFLIGHT_CHECK_PROCESSORS", false)
+	PreflightDBWait = time.Duration(envInt("PREFLIGHT_DB_WAIT_MS", 10000)) * time.Millisecond
+	switch policy := os.Getenv("HEALTH_FAILURE_POLICY"); policy {
+	case "open":
+		HealthFailOpen = true
+	case "", "closed":
+		HealthFailOpen = false
+	default:
+		logging.Warnf("Invalid HEALTH_FAILURE_POLICY=%q, using closed", policy)
+		HealthFailOpen = false
+	}
+	OrderedProcessing = envBool("ORDERED_PROCESSING", false)
+	OrderingPrefixLen = envInt("ORDERING_PREFIX_LEN", 0)
+	ShutdownGrace = time.Duration(envInt("SHUTDOWN_GRACE_MS", 5000)) * time.Millisecond
+	MaxProcessAttempts = envInt("MAX_PROCESS_ATTEMPTS", 1)
+	StreamBufferSize = envInt("STREAM_BUFFER_SIZE", 256)
+	SummarySnapshotInterval = time.Duration(envInt("SUMMARY_SNAPSHOT_S", 0)) * time.Second
+	LoggerBatchSize = envInt("LOGGER_BATCH_SIZE", 256)
+	if LoggerBatchSize < 1 || LoggerBatchSize > maxLoggerBatchSize {
+		logging.Warnf("LOGGER_BATCH_SIZE=%d out of range [1,%d], using 256", LoggerBatchSize, maxLoggerBatchSize)
+		LoggerBatchSize = 256
+	}
+	LoggerFlushInterval = time.Duration(envInt("LOGGER_FLUSH_MS", 200)) * time.Millisecond
+	if LoggerFlushInterval <= 0 {
+		logging.Warnf("LOGGER_FLUSH_MS must be positive, using 200")
+		LoggerFlushInterval = 200 * time.Millisecond
+	}
+	LoggerFlushWorkers = envInt("LOGGER_FLUSH_WORKERS", 1)
+	if LoggerFlushWorkers <= 0 {
+		logging.Warnf("LOGGER_FLUSH_WORKERS must be positive, using 1")
+		LoggerFlushWorkers = 1
+	}
+	CheapRoutingThreshold = envFloat("CHEAP_ROUTING_THRESHOLD", 0)
+	var err error
+	if ProcessorFeeSchedules, err = loadFeeSchedules(); err != nil {
+		logging.Warnf("Invalid fee schedule, routing by processor order: %v", err)
+	}
+	ProcessorWeights = envWeights("ROUTING_WEIGHTS")
+	HedgeAfter = time.Duration(envInt("HEDGE_AFTER_MS", 0)) * time.Millisecond
+	ProcessorHealthURLs = map[string]string{
+		"default":  os.Getenv("DEFAULT_HEALTH_URL"),
+		"fallback": os.Getenv("FALLBACK_HEALTH_URL"),
+	}
+	ProcessorHealthMethods = map[string]string{
+		"default":  envMethod("DEFAULT_PROCESSOR_HEALTH_METHOD"),
+		"fallback": envMethod("FALLBACK_PROCESSOR_HEALTH_METHOD"),
+	}
+	ProcessorSuccessCodes = map[string][]int{
+		"default":  envStatusCodes("DEFAULT_PROCESSOR_SUCCESS_CODES"),
+		"fallback": envStatusCodes("FALLBACK_PROCESSOR_SUCCESS_CODES"),
+	}
+	ProcessorHMACSecrets = map[string]string{
+		"default":  os.Getenv("DEFAULT_PROCESSOR_HMAC_SECRET"),
+		"fallback": os.Getenv("FALLBACK_PROCESSOR_HMAC_SECRET"),
+	}
+	ProcessorHMACAlgos = map[string]string{
+		"default":  envHMACAlgo("DEFAULT_PROCESSOR_HMAC_ALGO"),
+		"fallback": envHMACAlgo("FALLBACK_PROCESSOR_HMAC_ALGO"),
+	}
+	ProcessorFieldMaps = map[string]map[string]string{
+		"default":  envPairs("DEFAULT_PROCESSOR_FIELD_MAP"),
+		"fallback": envPairs("FALLBACK_PROCESSOR_FIELD_MAP"),
+	}
 
 	if PostgresDSN == "" {
-		log.Println("POSTGRES_DSN not set; skipping Postgres connection in config")
+		logging.Infof("POSTGRES_DSN not set; skipping Postgres connection in config")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Connection failures are left for Preflight to report.
 	cfg, err := pgxpool.ParseConfig(PostgresDSN)
 	if err != nil {
-		log.Fatalf("Invalid POSTGRES_DSN: %v", err)
+		postgresInitErr = fmt.Errorf("invalid POSTGRES_DSN: %w", err)
+		return
 	}
 	cfg.MinConns = 1
 	cfg.MaxConns = 4
+	if envBool("PURGE_ON_START", false) {
+		if confirm := os.Getenv("PURGE_ON_START_CONFIRM"); confirm != cfg.ConnConfig.Database {
+			logging.Warnf("PURGE_ON_START ignored: PURGE_ON_START_CONFIRM=%q does not name the database %q", confirm, cfg.ConnConfig.Database)
+		} else {
+			PurgeOnStart = true
+		}
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
-		log.Fatalf("Could not connect to Postgres: %v", err)
+		postgresInitErr = err
+		return
 	}
 	PostgresPool = pool
+	PostgresReadPool = pool
+	if dsn := os.Getenv("POSTGRES_READ_DSN"); dsn != "" {
+		if readPool, err := newReadPool(ctx, dsn); err != nil {
+			logging.Warnf("Could not set up read replica pool, reading from the primary: %v", err)
+		} else {
+			PostgresReadPool = readPool
+			logging.Infof("Using read replica for summary queries")
+		}
+	}
 
-	// Retry table creation with backoff
+	// Retry schema setup with backoff
 	for i := 0; i < 5; i++ {
-		if _, err = pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS payments (
-            correlation_id TEXT PRIMARY KEY,
-            amount NUMERIC,
-            processor TEXT,
-            created_at TIMESTAMPTZ DEFAULT now()
-        )`); err != nil {
-			log.Printf("Attempt %d: Could not ensure payments table: %v", i+1, err)
+		if err = EnsureSchema(ctx, pool); err != nil {
+			logging.Warnf("Attempt %d: Could not ensure database schema: %v", i+1, err)
 			if i < 4 {
-				time.Sleep(time.Duration(i+1) * time.Second)
+				time.Sleep(backoff.Delay(i+1, time.Second, 5*time.Second))
 				continue
 			}
-			log.Printf("Failed to create payments table after 5 attempts, continuing anyway: %v", err)
+			logging.Errorf("Failed to set up database schema after 5 attempts: %v", err)
 		} else {
 			break
 		}
 	}
 
-	log.Println("Connected to Postgres successfully!")
+	logging.Infof("Connected to Postgres successfully!")
+}
+
+// newReadPool connects to the read replica. Its sessions default to read-only
+// transactions, so a DSN pointing at the primary by mistake cannot write.
+func newReadPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid POSTGRES_READ_DSN: %w", err)
+	}
+	cfg.MinConns = 1
+	cfg.MaxConns = 4
+	cfg.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// EnsureSchema creates the payments table if it does not exist, then applies
+// the pending migrations unless RunMigrations is off. When PartitionByDay is
+// set the table is range-partitioned on created_at and the partitions for
+// today and tomorrow are created along with it.
+func EnsureSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	if !RunMigrations {
+		return nil
+	}
+	if err := createPaymentsTable(ctx, pool); err != nil {
+		return err
+	}
+	return Migrate(ctx, pool)
+}
+
+func createPaymentsTable(ctx context.Context, pool *pgxpool.Pool) error {
+	if !PartitionByDay {
+		_, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS payments (
+            correlation_id TEXT PRIMARY KEY,
+            amount NUMERIC,
+            processor TEXT,
+            created_at TIMESTAMPTZ DEFAULT now()
+        )`)
+		return err
+	}
+	// The partition key must be part of the primary key, so the key alone
+	// only makes correlation_id unique per day.
+	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS payments (
+            correlation_id TEXT NOT NULL,
+            amount NUMERIC,
+            processor TEXT,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            PRIMARY KEY (correlation_id, created_at)
+        ) PARTITION BY RANGE (created_at)`); err != nil {
+		return err
+	}
+	if err := createPaymentIDs(ctx, pool); err != nil {
+		return err
+	}
+	// Catch-all so inserts never fail if maintenance falls behind;
+	// EnsurePaymentPartitions warns when rows end up in it.
+	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS payments_default PARTITION OF payments DEFAULT`); err != nil {
+		return err
+	}
+	return EnsurePaymentPartitions(ctx, pool, time.Now())
+}
+
+// createPaymentIDs makes correlation_id unique across the partitioned
+// payments table. Every inserted row first claims its ID in the
+// unpartitioned payment_ids table; a row whose ID is already claimed is
+// skipped, as ON CONFLICT DO NOTHING would on the plain table, so the
+// gateway's received row and the worker's processed row stay one row.
+// Deleting or truncating payments releases the IDs again.
+func createPaymentIDs(ctx context.Context, pool *pgxpool.Pool) error {
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS payment_ids (correlation_id TEXT PRIMARY KEY)`,
+		`CREATE OR REPLACE FUNCTION payments_claim_id() RETURNS trigger LANGUAGE plpgsql AS $$
+        BEGIN
+            INSERT INTO payment_ids (correlation_id) VALUES (NEW.correlation_id) ON CONFLICT DO NOTHING;
+            IF NOT FOUND THEN
+                RETURN NULL;
+            END IF;
+            RETURN NEW;
+        END $$`,
+		`CREATE OR REPLACE FUNCTION payments_release_id() RETURNS trigger LANGUAGE plpgsql AS $$
+        BEGIN
+            DELETE FROM payment_ids WHERE correlation_id = OLD.correlation_id;
+            RETURN NULL;
+        END $$`,
+		`CREATE OR REPLACE FUNCTION payments_release_ids() RETURNS trigger LANGUAGE plpgsql AS $$
+        BEGIN
+            TRUNCATE payment_ids;
+            RETURN NULL;
+        END $$`,
+		`CREATE OR REPLACE TRIGGER payments_claim_id BEFORE INSERT ON payments
+            FOR EACH ROW EXECUTE FUNCTION payments_claim_id()`,
+		`CREATE OR REPLACE TRIGGER payments_release_id AFTER DELETE ON payments
+            FOR EACH ROW EXECUTE FUNCTION payments_release_id()`,
+		`CREATE OR REPLACE TRIGGER payments_release_ids AFTER TRUNCATE ON payments
+            FOR EACH STATEMENT EXECUTE FUNCTION payments_release_ids()`,
+	} {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("set up payment_ids: %w", err)
+		}
+	}
+	return nil
+}
+
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		logging.Warnf("Invalid %s=%q, using default %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		logging.Warnf("Invalid %s=%q, using default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		logging.Warnf("Invalid %s=%q, using default %g", key, v, def)
+		return def
+	}
+	return f
+}
+
+// HandlerTimeout returns the handler budget for a route pattern.
+func HandlerTimeout(pattern string) time.Duration {
+	if d, ok := routeTimeouts[pattern]; ok {
+		return d
+	}
+	return DefaultHandlerTimeout
+}
+
+// envMethod reads an HTTP method, defaulting to GET.
+func envMethod(key string) string {
+	v := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	if v == "" {
+		return "GET"
+	}
+	return v
+}
+
+// envHMACAlgo reads an HMAC hash name, defaulting to sha256.
+func envHMACAlgo(key string) string {
+	switch v := strings.ToLower(strings.TrimSpace(os.Getenv(key))); v {
+	case "":
+		return "sha256"
+	case "sha1", "sha256", "sha512":
+		return v
+	default:
+		logging.Warnf("Invalid %s=%q, using sha256", key, v)
+		return "sha256"
+	}
+}
+
+// envStatusCodes parses a comma-separated list of 2xx status codes, defaulting
+// to just 200.
+func envStatusCodes(key string) []int {
+	var codes []int
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		code, err := strconv.Atoi(item)
+		if err != nil || code < 200 || code > 299 {
+			logging.Warnf("Invalid status code %q in %s, ignoring", item, key)
+			continue
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return []int{200}
+	}
+	return codes
+}
+
+// envWeights parses name=weight pairs with non-negative weights. It returns
+// nil, disabling weighted routing, unless both processors are listed and the
+// weights do not add up to zero.
+func envWeights(key string) map[string]int {
+	pairs := envPairs(key)
+	if pairs == nil {
+		return nil
+	}
+	weights := make(map[string]int, len(pairs))
+	for name, v := range pairs {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			logging.Warnf("Invalid weight %q for %s in %s, ignoring %s", v, name, key, key)
+			return nil
+		}
+		weights[name] = n
+	}
+	for _, name := range []string{"default", "fallback"} {
+		if _, ok := weights[name]; !ok {
+			logging.Warnf("%s must weight both default and fallback, ignoring it", key)
+			return nil
+		}
+	}
+	if weights["default"]+weights["fallback"] == 0 {
+		logging.Warnf("%s weights add up to zero, ignoring it", key)
+		return nil
+	}
+	return weights
+}
+
+// envList parses a comma-separated list, skipping empty items.
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envPairs parses a comma-separated list of key=value pairs.
+func envPairs(key string) map[string]string {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || k == "" || val == "" {
+			logging.Warnf("Invalid entry %q in %s, ignoring", item, key)
+			continue
+		}
+		pairs[k] = val
+	}
+	return pairs
 }
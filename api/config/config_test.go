@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestHealthFailurePolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   bool
+	}{
+		{"", false},
+		{"closed", false},
+		{"open", true},
+		{"bogus", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			t.Setenv("HEALTH_FAILURE_POLICY", tt.policy)
+			Init()
+			if HealthFailOpen != tt.want {
+				t.Errorf("HEALTH_FAILURE_POLICY=%q: HealthFailOpen = %t, want %t", tt.policy, HealthFailOpen, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupBloomNeedsSoleWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		workerURLs string
+		soleWriter string
+		want       int
+	}{
+		{"not confirmed", "", "", 0},
+		{"sole writer", "", "true", 1 << 20},
+		{"several workers", "http://w1:8081,http://w2:8081", "true", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEDUP_BLOOM_BITS", "1048576")
+			t.Setenv("WORKER_URLS", tt.workerURLs)
+			t.Setenv("DEDUP_BLOOM_SOLE_WRITER", tt.soleWriter)
+			Init()
+			if DedupBloomBits != tt.want {
+				t.Errorf("DedupBloomBits = %d, want %d", DedupBloomBits, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreflightSettings(t *testing.T) {
+	tests := []struct {
+		wait, probe string
+		wantWait    time.Duration
+		wantProbe   bool
+	}{
+		{"", "", 10 * time.Second, false},
+		{"2500", "true", 2500 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("PREFLIGHT_DB_WAIT_MS", tt.wait)
+		t.Setenv("PREFLIGHT_CHECK_PROCESSORS", tt.probe)
+		Init()
+		if PreflightDBWait != tt.wantWait || PreflightCheckProcessors != tt.wantProbe {
+			t.Errorf("PREFLIGHT_DB_WAIT_MS=%q PREFLIGHT_CHECK_PROCESSORS=%q: got %s, %t; want %s, %t",
+				tt.wait, tt.probe, PreflightDBWait, PreflightCheckProcessors, tt.wantWait, tt.wantProbe)
+		}
+	}
+}
+
+// TestPurgeOnStartConfirm checks PURGE_ON_START only takes effect when
+// PURGE_ON_START_CONFIRM names the database of POSTGRES_DSN.
+func TestPurgeOnStartConfirm(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		confirm string
+		want    bool
+	}{
+		{"", false},
+		{cfg.Database + "_other", false},
+		{cfg.Database, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.confirm, func(t *testing.T) {
+			t.Setenv("POSTGRES_DSN", dsn)
+			t.Setenv("PURGE_ON_START", "true")
+			t.Setenv("PURGE_ON_START_CONFIRM", tt.confirm)
+			PurgeOnStart = false
+			Init()
+			defer PostgresPool.Close()
+			if PurgeOnStart != tt.want {
+				t.Errorf("PURGE_ON_START_CONFIRM=%q: PurgeOnStart = %t, want %t", tt.confirm, PurgeOnStart, tt.want)
+			}
+		})
+	}
+}
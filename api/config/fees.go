@@ -0,0 +1,62 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// FeeTier is the fee a processor charges on amounts from MinAmount up to the
+// next tier's MinAmount: Percent of the amount plus Fixed.
+type FeeTier struct {
+	MinAmount float64 `json:"minAmount"`
+	Percent   float64 `json:"percent"`
+	Fixed     float64 `json:"fixed"`
+}
+
+// loadFeeSchedules reads the per-processor fee tiers from FEE_SCHEDULE, or
+// from the file named by FEE_SCHEDULE_FILE, e.g.
+//
+//	{"default": [{"percent": 5}, {"minAmount": 1000, "percent": 4}],
+//	 "fallback": [{"percent": 15}]}
+func loadFeeSchedules() (map[string][]FeeTier, error) {
+	data := []byte(os.Getenv("FEE_SCHEDULE"))
+	if path := os.Getenv("FEE_SCHEDULE_FILE"); len(data) == 0 && path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var schedules map[string][]FeeTier
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, err
+	}
+	for name, tiers := range schedules {
+		if len(tiers) == 0 {
+			return nil, fmt.Errorf("processor %q has no fee tiers", name)
+		}
+		sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinAmount < tiers[j].MinAmount })
+	}
+	return schedules, nil
+}
+
+// ProcessorFee is the fee the named processor charges on amount, and false
+// when it has no fee schedule. Amounts below the lowest tier use that tier.
+func ProcessorFee(name string, amount float64) (float64, bool) {
+	tiers := ProcessorFeeSchedules[name]
+	if len(tiers) == 0 {
+		return 0, false
+	}
+	tier := tiers[0]
+	for _, t := range tiers[1:] {
+		if amount < t.MinAmount {
+			break
+		}
+		tier = t
+	}
+	return amount*tier.Percent/100 + tier.Fixed, true
+}
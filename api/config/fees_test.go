@@ -0,0 +1,44 @@
+package config
+
+import "testing"
+
+func TestProcessorFee(t *testing.T) {
+	// Tiers out of order, to check loadFeeSchedules sorts them.
+	t.Setenv("FEE_SCHEDULE", `{"default": [{"minAmount": 1000, "percent": 4}, {"minAmount": 100, "percent": 5, "fixed": 1}],
+		"fallback": [{"percent": 15}]}`)
+	schedules, err := loadFeeSchedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(s map[string][]FeeTier) { ProcessorFeeSchedules = s }(ProcessorFeeSchedules)
+	ProcessorFeeSchedules = schedules
+
+	tests := []struct {
+		name   string
+		amount float64
+		want   float64
+		wantOK bool
+	}{
+		{"default", 50, 3.5, true}, // below the lowest tier
+		{"default", 100, 6, true},
+		{"default", 999, 50.95, true},
+		{"default", 1000, 40, true},
+		{"fallback", 1000, 150, true},
+		{"unknown", 1000, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ProcessorFee(tt.name, tt.amount)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("ProcessorFee(%q, %v) = %v, %t; want %v, %t", tt.name, tt.amount, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLoadFeeSchedulesInvalid(t *testing.T) {
+	for _, schedule := range []string{`{"default": []}`, `{"default": {}}`, `not json`} {
+		t.Setenv("FEE_SCHEDULE", schedule)
+		if _, err := loadFeeSchedules(); err == nil {
+			t.Errorf("FEE_SCHEDULE=%s: no error", schedule)
+		}
+	}
+}
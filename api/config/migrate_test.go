@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TestPaymentStatusBackfill runs migration 0001 on a payments table from
+// before it, in a scratch schema of the database named by TEST_POSTGRES_DSN.
+func TestPaymentStatusBackfill(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	for _, sql := range []string{
+		"DROP SCHEMA IF EXISTS migrate_test CASCADE",
+		"CREATE SCHEMA migrate_test",
+		"SET search_path TO migrate_test",
+		"CREATE TABLE payments (correlation_id TEXT PRIMARY KEY, amount NUMERIC, processor TEXT)",
+		"INSERT INTO payments VALUES ('charged', 10, 'default'), ('logged', 10, NULL), ('blank', 10, '')",
+	} {
+		if _, err := conn.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer conn.Exec(ctx, "DROP SCHEMA migrate_test CASCADE")
+
+	sql, err := migrationFiles.ReadFile("migrations/0001_payment_status.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(ctx, string(sql)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"charged": "processed", "logged": "received", "blank": "received"}
+	for id, status := range want {
+		var got string
+		if err := conn.QueryRow(ctx, "SELECT status FROM payments WHERE correlation_id = $1", id).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != status {
+			t.Errorf("%s: status %s, want %s", id, got, status)
+		}
+	}
+}
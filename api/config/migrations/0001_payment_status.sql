@@ -0,0 +1,10 @@
+-- Processing outcome columns, added in place on existing deployments.
+ALTER TABLE payments
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'processed',
+    ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS last_error TEXT;
+
+-- Before these columns the gateway logged every received payment here too,
+-- without a processor; only rows with one were charged.
+UPDATE payments SET status = 'received'
+    WHERE status = 'processed' AND (processor IS NULL OR processor = '');
@@ -0,0 +1,8 @@
+-- Correlation IDs resubmitted with a different amount.
+CREATE TABLE IF NOT EXISTS payment_conflicts (
+    id BIGSERIAL PRIMARY KEY,
+    correlation_id TEXT NOT NULL,
+    existing_amount NUMERIC,
+    conflicting_amount NUMERIC,
+    detected_at TIMESTAMPTZ DEFAULT now()
+);
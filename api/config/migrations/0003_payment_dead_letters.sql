@@ -0,0 +1,7 @@
+-- Payments no processor accepted within MAX_PROCESS_ATTEMPTS passes.
+CREATE TABLE IF NOT EXISTS payment_dead_letters (
+    correlation_id TEXT PRIMARY KEY,
+    amount NUMERIC,
+    attempts INT NOT NULL,
+    failed_at TIMESTAMPTZ DEFAULT now()
+);
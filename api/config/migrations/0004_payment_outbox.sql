@@ -0,0 +1,8 @@
+-- Payments a worker accepted but had not finished when it shut down. The
+-- next worker to start drains it.
+CREATE TABLE IF NOT EXISTS payment_outbox (
+    correlation_id TEXT PRIMARY KEY,
+    amount NUMERIC,
+    attempts INT NOT NULL DEFAULT 0,
+    saved_at TIMESTAMPTZ DEFAULT now()
+);
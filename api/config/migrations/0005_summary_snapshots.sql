@@ -0,0 +1,8 @@
+-- Periodic summary rows written every SUMMARY_SNAPSHOT_S.
+CREATE TABLE IF NOT EXISTS summary_snapshots (
+    taken_at TIMESTAMPTZ PRIMARY KEY DEFAULT now(),
+    default_requests BIGINT NOT NULL,
+    default_amount NUMERIC NOT NULL,
+    fallback_requests BIGINT NOT NULL,
+    fallback_amount NUMERIC NOT NULL
+);
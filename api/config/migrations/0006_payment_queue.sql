@@ -0,0 +1,8 @@
+-- FORWARD_MODE=pull: the gateway appends payments and workers claim them
+-- with FOR UPDATE SKIP LOCKED.
+CREATE TABLE IF NOT EXISTS payment_queue (
+    id BIGSERIAL PRIMARY KEY,
+    correlation_id TEXT NOT NULL,
+    amount NUMERIC,
+    enqueued_at TIMESTAMPTZ DEFAULT now()
+);
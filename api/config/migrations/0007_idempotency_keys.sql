@@ -0,0 +1,6 @@
+-- Response status per Idempotency-Key header, kept for IDEMPOTENCY_TTL_S.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    status INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,14 @@
+-- HEALTH_LEADER_ELECTION: the single lease row and the readings the leader
+-- shares with the other workers.
+CREATE TABLE IF NOT EXISTS health_leader (
+    id INT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS processor_health (
+    name TEXT PRIMARY KEY,
+    healthy BOOLEAN NOT NULL,
+    min_response_time BIGINT NOT NULL,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,7 @@
+-- Totals of processed payments deleted by PAYMENT_RETENTION_S pruning, per
+-- processor ('' for none), added back into the summary.
+CREATE TABLE IF NOT EXISTS payments_pruned_totals (
+    processor TEXT PRIMARY KEY,
+    total_requests BIGINT NOT NULL,
+    total_amount NUMERIC NOT NULL
+);
@@ -0,0 +1,8 @@
+-- STORE_RAW_PAYLOADS: request bodies exactly as clients sent them, for
+-- MODE=replay.
+CREATE TABLE IF NOT EXISTS raw_payloads (
+    id BIGSERIAL PRIMARY KEY,
+    correlation_id TEXT NOT NULL,
+    body BYTEA NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+-- HEALTH_DEGRADED_MS: the leader shares whether a processor is degraded.
+ALTER TABLE processor_health ADD COLUMN IF NOT EXISTS degraded BOOLEAN NOT NULL DEFAULT false;
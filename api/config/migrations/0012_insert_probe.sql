@@ -0,0 +1,6 @@
+-- INSERT_FAILURE_LIMIT: a one-row table the worker writes to while payment
+-- inserts are failing, to find out when they work again.
+CREATE TABLE IF NOT EXISTS insert_probe (
+    id INT PRIMARY KEY,
+    probed_at TIMESTAMPTZ NOT NULL
+);
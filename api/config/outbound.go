@@ -0,0 +1,13 @@
+package config
+
+import "net/http"
+
+// SetOutboundHeaders adds OutboundUserAgent and OutboundHeaders to h. It is
+// called right after building an outbound request, so headers the request
+// sets itself (Content-Type, signatures, tokens) take precedence.
+func SetOutboundHeaders(h http.Header) {
+	h.Set("User-Agent", OutboundUserAgent)
+	for k, v := range OutboundHeaders {
+		h.Set(k, v)
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSetOutboundHeaders(t *testing.T) {
+	t.Setenv("OUTBOUND_USER_AGENT", "")
+	t.Setenv("OUTBOUND_HEADERS", "X-Team=rinha, X-Env=bench,broken,=empty")
+	Init()
+
+	h := http.Header{}
+	SetOutboundHeaders(h)
+	want := map[string]string{"User-Agent": "rinha-backend-golang", "X-Team": "rinha", "X-Env": "bench"}
+	if len(h) != len(want) {
+		t.Errorf("headers %v, want %v", h, want)
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	t.Setenv("OUTBOUND_USER_AGENT", "bench/1.0")
+	t.Setenv("OUTBOUND_HEADERS", "")
+	Init()
+	h = http.Header{}
+	SetOutboundHeaders(h)
+	if len(h) != 1 {
+		t.Errorf("headers %v, want only User-Agent", h)
+	}
+	if got := h.Get("User-Agent"); got != "bench/1.0" {
+		t.Errorf("User-Agent = %q, want bench/1.0", got)
+	}
+}
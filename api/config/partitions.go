@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/logging"
+)
+
+const partitionPrefix = "payments_p"
+
+// partitionName returns the name of the daily partition holding day (UTC).
+func partitionName(day time.Time) string {
+	return partitionPrefix + day.UTC().Format("20060102")
+}
+
+// EnsurePaymentPartitions creates the daily partitions for the day containing
+// now and the following day. It is a no-op when partitioning is disabled.
+// Rows in the default partition mean a day's partition was missing when
+// they arrived; they are reported, since they also keep that day's
+// partition from being created until they are moved out.
+func EnsurePaymentPartitions(ctx context.Context, pool *pgxpool.Pool, now time.Time) error {
+	if !PartitionByDay {
+		return nil
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	var errs []error
+	for _, day := range []time.Time{today, today.AddDate(0, 0, 1)} {
+		next := day.AddDate(0, 0, 1)
+		sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF payments FOR VALUES FROM ('%s') TO ('%s')`,
+			partitionName(day), day.Format(time.RFC3339), next.Format(time.RFC3339))
+		if _, err := pool.Exec(ctx, sql); err != nil {
+			errs = append(errs, fmt.Errorf("create partition %s: %w", partitionName(day), err))
+		}
+	}
+	var stray int64
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM payments_default").Scan(&stray); err != nil {
+		errs = append(errs, fmt.Errorf("check default partition: %w", err))
+	} else if stray > 0 {
+		logging.Warnf("%d payments are in the default partition payments_default; their daily partitions were missing", stray)
+	}
+	return errors.Join(errs...)
+}
+
+// DropExpiredPartitions drops daily partitions that ended more than
+// PartitionRetentionDays ago. Dropping a partition is much cheaper than
+// deleting its rows. A retention of zero keeps everything.
+func DropExpiredPartitions(ctx context.Context, pool *pgxpool.Pool, now time.Time) (int, error) {
+	if !PartitionByDay || PartitionRetentionDays <= 0 {
+		return 0, nil
+	}
+	rows, err := pool.Query(ctx, `SELECT c.relname FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'payments'::regclass`)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	cutoff := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -PartitionRetentionDays)
+	dropped := 0
+	for _, name := range names {
+		if !strings.HasPrefix(name, partitionPrefix) {
+			continue
+		}
+		day, err := time.Parse("20060102", strings.TrimPrefix(name, partitionPrefix))
+		if err != nil || !day.Before(cutoff) {
+			continue
+		}
+		// Dropping a partition fires no DELETE triggers, so its IDs are
+		// released from payment_ids first.
+		if _, err := pool.Exec(ctx, "DELETE FROM payment_ids WHERE correlation_id IN (SELECT correlation_id FROM "+name+")"); err != nil {
+			return dropped, fmt.Errorf("release IDs of partition %s: %w", name, err)
+		}
+		if _, err := pool.Exec(ctx, "DROP TABLE IF EXISTS "+name); err != nil {
+			return dropped, fmt.Errorf("drop partition %s: %w", name, err)
+		}
+		logging.Infof("Dropped expired partition %s", name)
+		dropped++
+	}
+	return dropped, nil
+}
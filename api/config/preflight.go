@@ -0,0 +1,160 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/logging"
+)
+
+// Severity of a failed preflight check.
+const (
+	CheckOK = iota
+	CheckWarn
+	CheckFail
+)
+
+// CheckResult is the outcome of one preflight check.
+type CheckResult struct {
+	Name     string
+	Severity int
+	Detail   string
+}
+
+// postgresInitErr records why Init could not set up PostgresPool, so the
+// preflight can report it instead of Init exiting on the spot.
+var postgresInitErr error
+
+// Preflight verifies the configuration for the given MODE before serving,
+// logs a summary line per check and reports whether startup may proceed.
+// Processor reachability is only probed with PreflightCheckProcessors and
+// is never fatal. A database still starting up is waited for up to
+// PreflightDBWait; one that stays unreachable only fails the modes that
+// cannot run without it.
+func Preflight(mode string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second+PreflightDBWait)
+	defer cancel()
+
+	results := runPreflight(ctx, mode, PreflightCheckProcessors, PreflightDBWait)
+	ok := true
+	for _, r := range results {
+		switch r.Severity {
+		case CheckOK:
+			logging.Infof("Preflight: [ok]   %s: %s", r.Name, r.Detail)
+		case CheckWarn:
+			logging.Warnf("Preflight: [warn] %s: %s", r.Name, r.Detail)
+		default:
+			logging.Errorf("Preflight: [FAIL] %s: %s", r.Name, r.Detail)
+			ok = false
+		}
+	}
+	if !ok {
+		logging.Errorf("Preflight: critical checks failed, exiting")
+	}
+	return ok
+}
+
+func runPreflight(ctx context.Context, mode string, probeProcessors bool, dbWait time.Duration) []CheckResult {
+	worker := mode == "worker" || mode == "combined"
+	var results []CheckResult
+
+	// The worker cannot route, dedup or record payments without these; the
+	// gateway only needs the database for its payment log.
+	required := map[string]string{"POSTGRES_DSN": PostgresDSN}
+	if worker {
+		required["DEFAULT_PROCESSOR_URL"] = DefaultProcessorURL
+		required["FALLBACK_PROCESSOR_URL"] = FallbackProcessorURL
+	}
+	for _, key := range []string{"POSTGRES_DSN", "DEFAULT_PROCESSOR_URL", "FALLBACK_PROCESSOR_URL"} {
+		v, ok := required[key]
+		if !ok {
+			continue
+		}
+		switch {
+		case v != "":
+			results = append(results, CheckResult{key, CheckOK, "set"})
+		case worker:
+			results = append(results, CheckResult{key, CheckFail, "not set"})
+		default:
+			results = append(results, CheckResult{key, CheckWarn, "not set, payment log disabled"})
+		}
+	}
+
+	results = append(results, checkDatabase(ctx, worker, dbWait)...)
+
+	if worker && probeProcessors {
+		results = append(results, checkProcessor(ctx, "default processor", DefaultProcessorURL))
+		results = append(results, checkProcessor(ctx, "fallback processor", FallbackProcessorURL))
+	}
+	return results
+}
+
+// checkDatabase pings the database until it answers or dbWait has passed.
+// Only the worker needs it to start; the gateway runs without its payment
+// log until the database is up, so for it failures are warnings.
+func checkDatabase(ctx context.Context, worker bool, dbWait time.Duration) []CheckResult {
+	if PostgresDSN == "" {
+		return nil
+	}
+	failed := CheckWarn
+	if worker {
+		failed = CheckFail
+	}
+	if PostgresPool == nil {
+		return []CheckResult{{"database", failed, fmt.Sprintf("not connected: %v", postgresInitErr)}}
+	}
+	if err := pingWithin(ctx, dbWait); err != nil {
+		return []CheckResult{{"database", failed, fmt.Sprintf("ping failed after %s: %v", dbWait, err)}}
+	}
+	results := []CheckResult{{"database", CheckOK, "connected"}}
+
+	var table *string
+	if err := PostgresPool.QueryRow(ctx, "SELECT to_regclass('payments')::text").Scan(&table); err != nil {
+		results = append(results, CheckResult{"payments table", failed, err.Error()})
+	} else if table == nil {
+		results = append(results, CheckResult{"payments table", failed, "does not exist"})
+	} else {
+		results = append(results, CheckResult{"payments table", CheckOK, "exists"})
+	}
+	return results
+}
+
+// pingWithin pings PostgresPool, retrying with backoff until it succeeds or
+// wait has passed.
+func pingWithin(ctx context.Context, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for attempt := 1; ; attempt++ {
+		err := PostgresPool.Ping(ctx)
+		if err == nil || !time.Now().Before(deadline) {
+			return err
+		}
+		logging.Warnf("Preflight: database not reachable yet (attempt %d): %v", attempt, err)
+		delay := min(backoff.Delay(attempt, 100*time.Millisecond, time.Second), time.Until(deadline))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+func checkProcessor(ctx context.Context, name, url string) CheckResult {
+	if url == "" {
+		return CheckResult{name, CheckWarn, "no URL"}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"/payments/service-health", nil)
+	if err != nil {
+		return CheckResult{name, CheckWarn, err.Error()}
+	}
+	SetOutboundHeaders(req.Header)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return CheckResult{name, CheckWarn, fmt.Sprintf("unreachable: %v", err)}
+	}
+	resp.Body.Close()
+	// Any HTTP answer, even 429, means the processor is reachable.
+	return CheckResult{name, CheckOK, fmt.Sprintf("reachable (HTTP %d)", resp.StatusCode)}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// unreachablePool returns a pool for a port nothing listens on, like a
+// database that has not started yet.
+func unreachablePool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	pool, err := pgxpool.New(context.Background(), "postgres://u:p@"+addr+"/db?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestPreflightDatabaseDown(t *testing.T) {
+	defer func(dsn string, pool *pgxpool.Pool, def, fallback string) {
+		PostgresDSN, PostgresPool, DefaultProcessorURL, FallbackProcessorURL = dsn, pool, def, fallback
+	}(PostgresDSN, PostgresPool, DefaultProcessorURL, FallbackProcessorURL)
+	PostgresDSN = "postgres://down"
+	PostgresPool = unreachablePool(t)
+	DefaultProcessorURL, FallbackProcessorURL = "http://default", "http://fallback"
+
+	tests := []struct {
+		mode string
+		want int
+	}{
+		{"gateway", CheckWarn},
+		{"", CheckWarn},
+		{"worker", CheckFail},
+		{"combined", CheckFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			const wait = 300 * time.Millisecond
+			start := time.Now()
+			results := runPreflight(context.Background(), tt.mode, false, wait)
+			if elapsed := time.Since(start); elapsed < wait || elapsed > wait+time.Second {
+				t.Errorf("preflight took %s, want about the %s database wait", elapsed, wait)
+			}
+			var db *CheckResult
+			for i := range results {
+				if results[i].Name == "database" {
+					db = &results[i]
+				}
+			}
+			if db == nil {
+				t.Fatalf("no database check in %v", results)
+			}
+			if db.Severity != tt.want {
+				t.Errorf("database check severity = %d (%s), want %d", db.Severity, db.Detail, tt.want)
+			}
+		})
+	}
+}
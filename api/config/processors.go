@@ -0,0 +1,37 @@
+package config
+
+import "sync/atomic"
+
+// processorURLs holds the processor base URLs in use by name. It starts as
+// DefaultProcessorURL and FallbackProcessorURL and can be replaced at
+// runtime, e.g. through the worker's POST /config/processors.
+var processorURLs atomic.Pointer[map[string]string]
+
+// ProcessorURL returns the current base URL of processor name ("default" or
+// "fallback").
+func ProcessorURL(name string) string {
+	if urls := processorURLs.Load(); urls != nil {
+		return (*urls)[name]
+	}
+	return ""
+}
+
+// SetProcessorURLs replaces the URLs of the processors named in urls and
+// keeps the others. Calls in flight finish against the old URL.
+func SetProcessorURLs(urls map[string]string) {
+	for {
+		old := processorURLs.Load()
+		next := make(map[string]string, 2)
+		if old != nil {
+			for name, u := range *old {
+				next[name] = u
+			}
+		}
+		for name, u := range urls {
+			next[name] = u
+		}
+		if processorURLs.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
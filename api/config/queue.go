@@ -0,0 +1,7 @@
+package config
+
+import "time"
+
+// PullIdleInterval is how long a worker waits before polling an empty
+// payment_queue again in pull mode.
+const PullIdleInterval = 50 * time.Millisecond
@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+
+	"rinha-backend-golang/models"
+)
+
+// forwardStats counts how often forwarding to the worker reused a pooled
+// connection versus dialing a new one. A high new-connection count means the
+// idle pool (WORKER_MAX_IDLE_CONNS_PER_HOST) is too small for the forwarders.
+type forwardStats struct {
+	reused  atomic.Int64
+	dialed  atomic.Int64
+	evicted atomic.Int64 // queued payments dropped by QUEUE_DROP_POLICY=oldest
+}
+
+// trace returns ctx instrumented to count the connection the request gets.
+func (s *forwardStats) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				s.reused.Add(1)
+			} else {
+				s.dialed.Add(1)
+			}
+		},
+	})
+}
+
+// handleForwardStats reports this gateway instance's connection reuse counters.
+func (api *APIGateway) handleForwardStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ForwardConnStats{
+		Reused:  api.forwardStats.reused.Load(),
+		New:     api.forwardStats.dialed.Load(),
+		Evicted: api.forwardStats.evicted.Load(),
+	})
+}
+
+// handleInflight reports the payments queued and being forwarded by this
+// gateway instance, plus the embedded worker's stages in combined mode.
+func (api *APIGateway) handleInflight(w http.ResponseWriter, r *http.Request) {
+	var c models.InflightCounts
+	if api.local != nil {
+		c = api.local.Inflight()
+	}
+	c.Queued = int64(api.paymentQueue.len())
+	c.Forwarding = api.forwarding.Load()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
@@ -4,52 +4,113 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"rinha-backend-golang/backoff"
 	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/middleware"
 	"rinha-backend-golang/models"
 )
 
 // APIGateway handles incoming payment requests and forwards them to the worker.
 type APIGateway struct {
-	paymentQueue chan models.PaymentRequest
+	paymentQueue paymentQueue
 	httpClient   *http.Client
 	logger       *PaymentLogger
+	idempotency  *idempotencyStore // nil when Idempotency-Key support is disabled
+	forwardStats forwardStats
+	workers      *workerPool
+	forwarding   atomic.Int64 // payments taken off the queue, not yet handed over
+	local        LocalWorker  // set in combined mode
+	summary      SummaryFunc  // backs the GetSummary RPC
+
+	// Shutdown coordination: accepting turns false and paymentQueue is closed
+	// under the write lock; senders hold the read lock.
+	accepting  atomic.Bool
+	queueMu    sync.RWMutex
+	forwarders sync.WaitGroup
+}
+
+// LocalWorker is a worker running in the gateway's process (MODE=combined).
+// Payments are handed to it with a function call instead of an HTTP forward,
+// its routes are served alongside /payments and it is drained on shutdown.
+type LocalWorker interface {
+	// Submit starts processing req, reporting false when at capacity.
+	Submit(req models.PaymentRequest) bool
+	Handler() http.Handler
+	// Drain waits for in-flight payments and saves unfinished ones.
+	Drain()
+	// Inflight counts the payments it holds at each stage.
+	Inflight() models.InflightCounts
+}
+
+// EmbedWorker makes the gateway hand payments to w in-process.
+func (api *APIGateway) EmbedWorker(w LocalWorker) {
+	api.local = w
 }
 
 // NewAPIGateway creates a new APIGateway instance.
 func NewAPIGateway() *APIGateway {
-	return &APIGateway{
-		paymentQueue: make(chan models.PaymentRequest, config.QueueSize),
+	api := &APIGateway{
+		paymentQueue: newPaymentQueue(config.QueueOrder, config.QueueSize),
 		httpClient: &http.Client{
 			Timeout: config.PaymentTimeout,
 			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 50,
-				IdleConnTimeout:     60 * time.Second,
+				DialContext:         (&net.Dialer{Timeout: config.DialTimeout}).DialContext,
+				TLSHandshakeTimeout: config.TLSHandshakeTimeout,
+				MaxIdleConns:        config.WorkerMaxIdleConns,
+				MaxIdleConnsPerHost: config.WorkerMaxIdleConnsPerHost,
+				IdleConnTimeout:     config.WorkerIdleConnTimeout,
 			},
 		},
-		logger: NewPaymentLogger(),
+		workers:     newWorkerPool(config.WorkerURLs),
+		logger:      NewPaymentLogger(),
+		idempotency: newIdempotencyStore(config.PostgresPool),
 	}
+	api.accepting.Store(true)
+	return api
 }
 
 // Start initializes the API Gateway and starts listening for requests.
 func (api *APIGateway) Start() {
+	api.forwarders.Add(config.NumWorkers)
 	for i := 0; i < config.NumWorkers; i++ {
 		go api.paymentForwarder()
 	}
-	http.HandleFunc("/payments", api.handlePayments)
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux := http.NewServeMux()
+	mux.Handle("/payments", middleware.BodyReadTimeout(config.PaymentsBodyTimeout,
+		middleware.Timeout("/payments", http.HandlerFunc(api.handlePayments))))
+	middleware.HandleFunc(mux, "/forward-stats", api.handleForwardStats)
+	middleware.HandleFunc(mux, "/debug/inflight", api.handleInflight)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	log.Printf("API Gateway starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	if config.Protocol == "grpc" {
+		logging.Infof("API Gateway starting gRPC on port %s", port)
+		api.serve(api.newGRPCServer(":" + port))
+		return
+	}
+	if api.local != nil {
+		// Everything but the gateway's own routes goes to the embedded worker.
+		mux.Handle("/", api.local.Handler())
+		logging.Infof("API Gateway starting on port %s with embedded worker", port)
+	} else {
+		logging.Infof("API Gateway starting on port %s", port)
+	}
+	api.serve(&http.Server{Addr: ":" + port, Handler: mux})
 }
 
 func (api *APIGateway) handlePayments(w http.ResponseWriter, r *http.Request) {
@@ -57,39 +118,297 @@ func (api *APIGateway) handlePayments(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if config.StrictContentType && !isJSONContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	deadline, err := models.ParseDeadline(r.Header.Get(models.DeadlineHeader), r.Header.Get(models.TimeoutHeader), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		http.Error(w, "Deadline exceeded", http.StatusRequestTimeout)
+		return
+	}
 	var req models.PaymentRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if config.StoreRawPayloads {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || json.Unmarshal(body, &req) != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.RawBody = body
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	select {
-	case api.paymentQueue <- req:
-		// Persist asynchronously
-		api.logger.LogPayment(req)
+	req.Deadline = deadline
+	req.PartitionKey = r.Header.Get(models.PartitionKeyHeader)
+	if errs := req.Validate(); errs != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(models.ValidationErrorResponse{Errors: errs})
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" && api.idempotency != nil {
+		owned, status, err := api.idempotency.claim(r.Context(), key)
+		if err != nil {
+			logging.Errorf("Gateway: idempotency lookup error: %v", err)
+		} else if !owned && status == statusInProgress {
+			http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+			return
+		} else if !owned {
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(status)
+			return
+		}
+	}
+	err = api.accept(r.Context(), req)
+	if err == nil {
+		if key != "" && api.idempotency != nil {
+			api.idempotency.complete(context.Background(), key, http.StatusOK)
+		}
 		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if key != "" && api.idempotency != nil {
+		// Not r.Context(): it is already cancelled if the handler timed out.
+		api.idempotency.release(context.Background(), key)
+	}
+	if errors.Is(err, errDeadlineExceeded) {
+		http.Error(w, "Deadline exceeded", http.StatusRequestTimeout)
+		return
+	}
+	if errors.Is(err, errWorkerUnreachable) {
+		http.Error(w, "Worker unreachable", http.StatusBadGateway)
+		return
+	}
+	if errors.Is(err, errAmountConflict) {
+		http.Error(w, "Correlation ID already used with a different amount", http.StatusConflict)
+		return
+	}
+	http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+}
+
+var (
+	errUnavailable       = errors.New("payment could not be accepted")
+	errDeadlineExceeded  = errors.New("deadline exceeded")
+	errWorkerUnreachable = errors.New("worker did not accept the payment")
+	errAmountConflict    = errors.New("correlation ID already used with a different amount")
+)
+
+// accept records and queues a validated payment. It fails with errUnavailable
+// when the payment could not be stored or queued, or errDeadlineExceeded when
+// the client deadline passed while waiting for room in the queue. With
+// config.SyncForward the payment is forwarded instead of queued, failing
+// with errWorkerUnreachable when the worker could not be reached or refused
+// it other than for being at capacity. With either option it fails with
+// errAmountConflict when the correlation ID is already stored with a
+// different amount.
+func (api *APIGateway) accept(ctx context.Context, req models.PaymentRequest) error {
+	if config.DurableAccept {
+		// Commit the row before anything else so a success response always
+		// means the payment is on disk. If it is not enqueued after all, the
+		// row simply stays received.
+		if err := api.logger.LogPaymentSync(ctx, req); errors.Is(err, errAmountConflict) {
+			return err
+		} else if err != nil {
+			logging.Errorf("Gateway: durable write of payment %s failed: %v", req.CorrelationID, err)
+			return errUnavailable
+		}
+	}
+	if config.SyncForward {
+		if !config.DurableAccept {
+			// The client waits for the outcome anyway, so it can be told
+			// about a reused correlation ID rather than have it skipped.
+			if err := api.logger.CheckConflict(ctx, req); errors.Is(err, errAmountConflict) {
+				return err
+			} else if err != nil {
+				logging.Warnf("Gateway: conflict check for payment %s failed: %v", req.CorrelationID, err)
+			}
+		}
+		return api.forwardSync(req)
+	}
+	if api.enqueue(ctx, req) {
+		if !config.DurableAccept {
+			// Persist asynchronously
+			api.logger.LogPayment(req)
+		}
+		return nil
+	}
+	if !req.Deadline.IsZero() {
+		return errDeadlineExceeded
+	}
+	return errUnavailable
+}
+
+// forwardSync forwards req from the handler itself, so a success response
+// means a worker took the payment rather than that it was queued.
+func (api *APIGateway) forwardSync(req models.PaymentRequest) error {
+	if !api.accepting.Load() {
+		return errUnavailable
+	}
+	api.forwarding.Add(1)
+	err := api.forwardPayment(req)
+	api.forwarding.Add(-1)
+	switch {
+	case err == nil:
+		if !config.DurableAccept {
+			api.logger.LogPayment(req)
+		}
+		return nil
+	case errors.Is(err, errWorkerBusy):
+		return errUnavailable
 	default:
-		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		logging.Errorf("Gateway: forwarding payment %s failed: %v", req.CorrelationID, err)
+		return errWorkerUnreachable
 	}
 }
 
+// enqueue hands req to the forwarders. Without a client deadline it never
+// blocks; with one it waits for room in the queue until the deadline, or
+// until ctx is done (the handler timed out or the client went away). It
+// refuses payments once shutdown has begun.
+func (api *APIGateway) enqueue(ctx context.Context, req models.PaymentRequest) bool {
+	api.queueMu.RLock()
+	defer api.queueMu.RUnlock()
+	if !api.accepting.Load() {
+		return false
+	}
+	if req.Deadline.IsZero() {
+		if api.paymentQueue.tryPush(req) {
+			return true
+		}
+		if config.QueueDropOldest {
+			return api.evictOldest(req)
+		}
+		return false
+	}
+	timer := time.NewTimer(time.Until(req.Deadline))
+	defer timer.Stop()
+	return api.paymentQueue.pushWait(ctx, timer.C, req)
+}
+
+// evictOldest makes room in the full queue by dropping its oldest payment,
+// or with QUEUE_ORDER=deadline the one with the latest deadline, then queues
+// req. The evicted payment was already acknowledged, so it is lost; the
+// policy trades it for the fresher one. Callers hold queueMu.
+func (api *APIGateway) evictOldest(req models.PaymentRequest) bool {
+	for i := 0; i < 3; i++ {
+		if old, ok := api.paymentQueue.evict(); ok {
+			api.forwardStats.evicted.Add(1)
+			logging.Warnf("Gateway: queue full, evicted payment %s for %s", old.CorrelationID, req.CorrelationID)
+		}
+		if api.paymentQueue.tryPush(req) {
+			return true
+		}
+		// A concurrent enqueue took the slot; try again.
+	}
+	return false
+}
+
+// isJSONContentType reports whether a Content-Type header value is
+// application/json, ignoring parameters such as charset.
+func isJSONContentType(v string) bool {
+	mediaType, _, err := mime.ParseMediaType(v)
+	return err == nil && mediaType == "application/json"
+}
+
+// workerBusyBackoff is how long a forwarder waits before re-queueing a payment
+// the worker refused because it was at capacity.
+const workerBusyBackoff = 10 * time.Millisecond
+
+var errWorkerBusy = errors.New("worker at capacity")
+
 func (api *APIGateway) paymentForwarder() {
-	for req := range api.paymentQueue {
-		api.forwardPayment(req)
+	defer api.forwarders.Done()
+	for {
+		req, ok := api.paymentQueue.pop()
+		if !ok {
+			return
+		}
+		api.forwarding.Add(1)
+		api.forwardOne(req)
+		api.forwarding.Add(-1)
+	}
+}
+
+// forwardOne hands one dequeued payment to the worker, re-queueing it when
+// the worker is at capacity.
+func (api *APIGateway) forwardOne(req models.PaymentRequest) {
+	if !req.Deadline.IsZero() && !time.Now().Before(req.Deadline) {
+		logging.Paymentf(req.CorrelationID, "Gateway: dropping payment %s past its deadline", req.CorrelationID)
+		return
+	}
+	if err := api.forwardPayment(req); errors.Is(err, errWorkerBusy) {
+		time.Sleep(backoff.Jittered(workerBusyBackoff))
+		switch {
+		case api.requeue(req):
+		case !api.accepting.Load():
+			api.forwardBusy(req)
+		default:
+			logging.Warnf("Gateway: queue full, dropping payment %s refused by busy worker", req.CorrelationID)
+		}
 	}
 }
 
-func (api *APIGateway) forwardPayment(req models.PaymentRequest) {
+func (api *APIGateway) forwardPayment(req models.PaymentRequest) error {
+	if api.local != nil {
+		if !api.local.Submit(req) {
+			return errWorkerBusy
+		}
+		return nil
+	}
+	if config.ForwardMode == "pull" {
+		return api.enqueueForPull(req)
+	}
 	reqBody, _ := json.Marshal(req)
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", config.WorkerURL+"/process-payment", bytes.NewReader(reqBody))
+	target := api.workers.acquire()
+	httpReq, err := http.NewRequestWithContext(api.forwardStats.trace(ctx), "POST", target.url+"/process-payment", bytes.NewReader(reqBody))
 	if err != nil {
-		return
+		api.workers.release(target, false)
+		return err
 	}
+	config.SetOutboundHeaders(httpReq.Header)
 	httpReq.Header.Set("Content-Type", "application/json")
+	if !req.Deadline.IsZero() {
+		httpReq.Header.Set(models.DeadlineHeader, req.Deadline.Format(time.RFC3339Nano))
+	}
+	if req.PartitionKey != "" {
+		httpReq.Header.Set(models.PartitionKeyHeader, req.PartitionKey)
+	}
 	resp, err := api.httpClient.Do(httpReq)
 	if err != nil {
-		return
+		api.workers.release(target, true)
+		return err
 	}
 	resp.Body.Close()
+	// 503 is the worker shedding load, not failing.
+	api.workers.release(target, resp.StatusCode >= 500 && resp.StatusCode != http.StatusServiceUnavailable)
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return errWorkerBusy
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("worker answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// enqueueForPull hands a payment to the workers through the payment_queue
+// table instead of an HTTP push.
+func (api *APIGateway) enqueueForPull(req models.PaymentRequest) error {
+	if config.PostgresPool == nil {
+		return errors.New("pull mode requires POSTGRES_DSN")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	_, err := config.PostgresPool.Exec(ctx, "INSERT INTO payment_queue (correlation_id, amount) VALUES ($1,$2)", req.CorrelationID, req.Amount)
+	if err != nil {
+		logging.Errorf("Gateway: could not enqueue payment %s for pull: %v", req.CorrelationID, err)
+	}
+	return err
 }
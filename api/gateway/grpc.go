@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+	"rinha-backend-golang/paymentspb"
+)
+
+// SummaryFunc computes the payments summary served by the GetSummary RPC.
+type SummaryFunc func(ctx context.Context) (models.PaymentSummaryResponse, error)
+
+// SetSummarySource sets where GetSummary reads the summary from. Without
+// one, GetSummary fails with UNIMPLEMENTED.
+func (api *APIGateway) SetSummarySource(f SummaryFunc) {
+	api.summary = f
+}
+
+// grpcService implements paymentspb.Payments on top of the same validation,
+// queue and payment log as POST /payments.
+type grpcService struct {
+	paymentspb.UnimplementedPaymentsServer
+	api *APIGateway
+}
+
+func (s *grpcService) SubmitPayment(ctx context.Context, in *paymentspb.SubmitPaymentRequest) (*paymentspb.SubmitPaymentResponse, error) {
+	req := models.PaymentRequest{
+		CorrelationID: in.GetCorrelationId(),
+		Amount:        models.Amount(in.GetAmount()),
+		PartitionKey:  in.GetPartitionKey(),
+	}
+	// The call deadline plays the role of the HTTP deadline headers.
+	if deadline, ok := ctx.Deadline(); ok {
+		if !time.Now().Before(deadline) {
+			return nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+		}
+		req.Deadline = deadline
+	}
+	if errs := req.Validate(); errs != nil {
+		return nil, status.Error(codes.InvalidArgument, validationMessage(errs))
+	}
+	switch err := s.api.accept(ctx, req); {
+	case err == nil:
+		return &paymentspb.SubmitPaymentResponse{}, nil
+	case errors.Is(err, errDeadlineExceeded):
+		return nil, status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	case errors.Is(err, errAmountConflict):
+		// Retrying cannot help, unlike the codes below.
+		return nil, status.Error(codes.AlreadyExists, "correlation ID already used with a different amount")
+	case errors.Is(err, errWorkerUnreachable):
+		return nil, status.Error(codes.Unavailable, "worker unreachable")
+	default:
+		// The HTTP 503: the queue or worker is full, the gateway is
+		// shutting down, or the durable write failed. Worth retrying later.
+		return nil, status.Error(codes.ResourceExhausted, "service unavailable")
+	}
+}
+
+func (s *grpcService) GetSummary(ctx context.Context, _ *paymentspb.GetSummaryRequest) (*paymentspb.PaymentSummary, error) {
+	if s.api.summary == nil {
+		return nil, status.Error(codes.Unimplemented, "no summary source configured")
+	}
+	summary, err := s.api.summary(ctx)
+	if err != nil {
+		logging.Errorf("Gateway: gRPC summary error: %v", err)
+		return nil, status.Error(codes.Internal, "db error")
+	}
+	out := &paymentspb.PaymentSummary{
+		DefaultProcessor:  toPBSummary(summary.Default),
+		FallbackProcessor: toPBSummary(summary.Fallback),
+	}
+	if summary.DryRun != nil {
+		out.DryRun = toPBSummary(*summary.DryRun)
+	}
+	if summary.Other != nil {
+		out.Other = toPBSummary(*summary.Other)
+	}
+	return out, nil
+}
+
+func toPBSummary(s models.Summary) *paymentspb.Summary {
+	return &paymentspb.Summary{TotalRequests: s.TotalRequests, TotalAmount: s.TotalAmount}
+}
+
+// validationMessage joins field errors into one status message, e.g.
+// "amount: must be greater than zero".
+func validationMessage(errs []models.FieldError) string {
+	msg := ""
+	for i, e := range errs {
+		if i > 0 {
+			msg += "; "
+		}
+		msg += fmt.Sprintf("%s: %s", e.Field, e.Reason)
+	}
+	return msg
+}
+
+// grpcServer adapts a grpc.Server to the ListenAndServe/Shutdown pair that
+// serve drives.
+type grpcServer struct {
+	addr string
+	srv  *grpc.Server
+}
+
+func (api *APIGateway) newGRPCServer(addr string) *grpcServer {
+	srv := grpc.NewServer()
+	paymentspb.RegisterPaymentsServer(srv, &grpcService{api: api})
+	return &grpcServer{addr: addr, srv: srv}
+}
+
+func (g *grpcServer) ListenAndServe() error {
+	lis, err := net.Listen("tcp", g.addr)
+	if err != nil {
+		return err
+	}
+	return g.srv.Serve(lis)
+}
+
+// Shutdown stops accepting calls and waits for running ones, cutting them
+// off when ctx is done.
+func (g *grpcServer) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		g.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		g.srv.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+	"rinha-backend-golang/paymentspb"
+)
+
+// grpcClient serves api's Payments service over an in-memory listener and
+// returns a client for it.
+func grpcClient(t *testing.T, api *APIGateway) paymentspb.PaymentsClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := api.newGRPCServer("").srv
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return paymentspb.NewPaymentsClient(conn)
+}
+
+func TestGRPCSubmitPayment(t *testing.T) {
+	defer func(n int) { config.CorrelationIDMaxLen = n }(config.CorrelationIDMaxLen)
+	config.CorrelationIDMaxLen = 36
+	const id = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+
+	tests := []struct {
+		name      string
+		queueSize int
+		req       *paymentspb.SubmitPaymentRequest
+		timeout   time.Duration // 0 for no call deadline
+		want      codes.Code
+	}{
+		{"accepted", 1, &paymentspb.SubmitPaymentRequest{CorrelationId: id, Amount: "19.90"}, 0, codes.OK},
+		{"invalid", 1, &paymentspb.SubmitPaymentRequest{CorrelationId: "not-a-uuid", Amount: "0"}, 0, codes.InvalidArgument},
+		{"no room before the deadline", 0, &paymentspb.SubmitPaymentRequest{CorrelationId: id, Amount: "19.90"}, 50 * time.Millisecond, codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &APIGateway{paymentQueue: newPaymentQueue("", tt.queueSize)}
+			api.accepting.Store(true)
+			client := grpcClient(t, api)
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			_, err := client.SubmitPayment(ctx, tt.req)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("SubmitPayment = %v, want %s", err, tt.want)
+			}
+			if tt.want == codes.OK {
+				if req, ok := api.paymentQueue.evict(); !ok || req.CorrelationID != id || req.Amount != "19.90" {
+					t.Errorf("queued %+v, %v; want the submitted payment", req, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCGetSummary(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      SummaryFunc
+		want        codes.Code
+		wantDefault int64
+	}{
+		{"no source", nil, codes.Unimplemented, 0},
+		{"stub source", func(context.Context) (models.PaymentSummaryResponse, error) {
+			return models.PaymentSummaryResponse{Default: models.Summary{TotalRequests: 3, TotalAmount: 59.7}}, nil
+		}, codes.OK, 3},
+		{"failing source", func(context.Context) (models.PaymentSummaryResponse, error) {
+			return models.PaymentSummaryResponse{}, errors.New("db down")
+		}, codes.Internal, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &APIGateway{}
+			api.SetSummarySource(tt.source)
+			out, err := grpcClient(t, api).GetSummary(context.Background(), &paymentspb.GetSummaryRequest{})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("GetSummary = %v, want %s", err, tt.want)
+			}
+			if got := out.GetDefaultProcessor().GetTotalRequests(); got != tt.wantDefault {
+				t.Errorf("default requests = %d, want %d", got, tt.wantDefault)
+			}
+		})
+	}
+}
+
+func TestGRPCSubmitPaymentFailures(t *testing.T) {
+	defer func(n int, sync bool) { config.CorrelationIDMaxLen, config.SyncForward = n, sync }(config.CorrelationIDMaxLen, config.SyncForward)
+	config.CorrelationIDMaxLen = 36
+	req := &paymentspb.SubmitPaymentRequest{CorrelationId: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", Amount: "19.90"}
+
+	tests := []struct {
+		name        string
+		syncForward bool
+		want        codes.Code
+	}{
+		{"queue full", false, codes.ResourceExhausted},
+		{"worker unreachable", true, codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.SyncForward = tt.syncForward
+			srv, _ := workerStub(t, http.StatusInternalServerError)
+			api := &APIGateway{
+				paymentQueue: newPaymentQueue("", 0),
+				httpClient:   &http.Client{},
+				workers:      newWorkerPool([]string{srv.URL}),
+			}
+			api.accepting.Store(true)
+			if _, err := grpcClient(t, api).SubmitPayment(context.Background(), req); status.Code(err) != tt.want {
+				t.Errorf("SubmitPayment = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
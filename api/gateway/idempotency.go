@@ -0,0 +1,68 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// idempotencyStore remembers the response status returned for each
+// Idempotency-Key header for config.IdempotencyTTL, so a client retrying a
+// POST gets the original answer instead of enqueueing the payment twice. Keys
+// live in Postgres so they are shared by every gateway instance.
+type idempotencyStore struct {
+	pool *pgxpool.Pool
+	ttl  time.Duration
+}
+
+func newIdempotencyStore(pool *pgxpool.Pool) *idempotencyStore {
+	if pool == nil || config.IdempotencyTTL <= 0 {
+		return nil
+	}
+	return &idempotencyStore{pool: pool, ttl: config.IdempotencyTTL}
+}
+
+// statusInProgress is stored for a claimed key until the request's outcome
+// is known, so a retry racing the original is not told it succeeded.
+const statusInProgress = 0
+
+// claim reserves key for this request. It returns owned=true when the caller
+// should process the request; otherwise status is the response recorded for
+// the earlier request with the same key, or statusInProgress while that
+// request is still being handled.
+func (s *idempotencyStore) claim(ctx context.Context, key string) (owned bool, status int, err error) {
+	// Claim the key unless a live (within TTL) entry already holds it.
+	err = s.pool.QueryRow(ctx, `INSERT INTO idempotency_keys (key, status) VALUES ($1, $2)
+        ON CONFLICT (key) DO UPDATE SET status = EXCLUDED.status, created_at = now()
+        WHERE idempotency_keys.created_at < now() - $3::interval
+        RETURNING status`, key, statusInProgress, s.ttl).Scan(&status)
+	if err == nil {
+		return true, status, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, 0, err
+	}
+	err = s.pool.QueryRow(ctx, "SELECT status FROM idempotency_keys WHERE key = $1", key).Scan(&status)
+	return false, status, err
+}
+
+// complete records status as the response for the claimed key.
+func (s *idempotencyStore) complete(ctx context.Context, key string, status int) {
+	if _, err := s.pool.Exec(ctx, "UPDATE idempotency_keys SET status = $2 WHERE key = $1", key, status); err != nil {
+		logging.Errorf("Gateway: could not record idempotency key %s: %v", key, err)
+	}
+}
+
+// release forgets key, used when the claimed request was not accepted so a
+// retry can succeed.
+func (s *idempotencyStore) release(ctx context.Context, key string) {
+	if _, err := s.pool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key); err != nil {
+		logging.Errorf("Gateway: could not release idempotency key %s: %v", key, err)
+	}
+}
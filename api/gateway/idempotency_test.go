@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+func TestIdempotencyRepeatedKey(t *testing.T) {
+	pool := testLogger(t).pool
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, "TRUNCATE idempotency_keys"); err != nil {
+		t.Fatal(err)
+	}
+	defer func(n int) { config.CorrelationIDMaxLen = n }(config.CorrelationIDMaxLen)
+	config.CorrelationIDMaxLen = 36
+	store := &idempotencyStore{pool: pool, ttl: time.Hour}
+	api := &APIGateway{idempotency: store}
+
+	owned, _, err := store.claim(ctx, "key-1")
+	if err != nil || !owned {
+		t.Fatalf("first claim = %v, %v; want owned", owned, err)
+	}
+
+	steps := []struct {
+		name     string
+		before   func()
+		want     int
+		replayed bool
+	}{
+		{"original still running", func() {}, http.StatusConflict, false},
+		{"original accepted", func() { store.complete(ctx, "key-1", http.StatusOK) }, http.StatusOK, true},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			step.before()
+			rec := httptest.NewRecorder()
+			body := `{"correlationId":"` + conflictID + `","amount":10.00}`
+			r := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body))
+			r.Header.Set("Idempotency-Key", "key-1")
+			api.handlePayments(rec, r)
+			if rec.Code != step.want {
+				t.Errorf("status = %d, want %d", rec.Code, step.want)
+			}
+			if got := rec.Header().Get("Idempotent-Replayed") == "true"; got != step.replayed {
+				t.Errorf("replayed = %v, want %v", got, step.replayed)
+			}
+		})
+	}
+}
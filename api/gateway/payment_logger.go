@@ -2,13 +2,18 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"log"
 	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
 	"rinha-backend-golang/models"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -28,17 +33,25 @@ import (
 //	);
 //
 // PaymentLogger will create the table automatically on start-up if it does not
-// yet exist.
-const (
-	flushInterval = 200 * time.Millisecond // max latency before a batch is flushed
-	batchSize     = 256                    // up to this many rows per INSERT
-)
-
+// yet exist (partitioned by day when PARTITION_BY_DAY is set), along with the
+// rest of the schema in config/migrations, unless DB_MIGRATE is off.
+//
+// The batch size (LOGGER_BATCH_SIZE), flush interval (LOGGER_FLUSH_MS) and
+// number of concurrent flushes (LOGGER_FLUSH_WORKERS) are taken from config.
 type PaymentLogger struct {
-	pool   *pgxpool.Pool
-	ch     chan models.PaymentRequest
-	ctx    context.Context
-	cancel context.CancelFunc
+	pool          *pgxpool.Pool
+	ch            chan models.PaymentRequest
+	ctx           context.Context
+	cancel        context.CancelFunc
+	done          chan struct{} // closed when loop has returned
+	batchSize     int           // up to this many rows per INSERT
+	flushInterval time.Duration // max latency before a batch is flushed
+	flushWorkers  int           // batches flushed concurrently
+
+	// Rows submitted and actually inserted; the difference are rows that
+	// ON CONFLICT DO NOTHING skipped as duplicates.
+	submitted atomic.Int64
+	inserted  atomic.Int64
 }
 
 func NewPaymentLogger() *PaymentLogger {
@@ -49,33 +62,34 @@ func NewPaymentLogger() *PaymentLogger {
 	}
 	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		log.Printf("PaymentLogger: invalid POSTGRES_DSN: %v", err)
+		logging.Errorf("PaymentLogger: invalid POSTGRES_DSN: %v", err)
 		return nil
 	}
 	cfg.MinConns = 1
-	cfg.MaxConns = 4
+	// One connection per flush worker, plus headroom for LogPaymentSync.
+	cfg.MaxConns = int32(max(4, config.LoggerFlushWorkers+1))
 	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
 	if err != nil {
-		log.Printf("PaymentLogger: could not connect to Postgres: %v", err)
+		logging.Errorf("PaymentLogger: could not connect to Postgres: %v", err)
 		return nil
 	}
 	// Ensure schema exists.
-	if _, err = pool.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS payments (
-            correlation_id TEXT PRIMARY KEY,
-            amount NUMERIC,
-            processor TEXT,
-            created_at TIMESTAMPTZ DEFAULT now()
-        )`); err != nil {
-		log.Printf("PaymentLogger: create table error: %v", err)
+	if err = config.EnsureSchema(context.Background(), pool); err != nil {
+		logging.Errorf("PaymentLogger: schema setup error: %v", err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	pl := &PaymentLogger{
-		pool:   pool,
-		ch:     make(chan models.PaymentRequest, 4096),
-		ctx:    ctx,
-		cancel: cancel,
+		pool:          pool,
+		ch:            make(chan models.PaymentRequest, 4096),
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+		batchSize:     config.LoggerBatchSize,
+		flushInterval: config.LoggerFlushInterval,
+		flushWorkers:  config.LoggerFlushWorkers,
 	}
+	logging.Infof("PaymentLogger: batch size %d, flush interval %s, %d flush workers", pl.batchSize, pl.flushInterval, pl.flushWorkers)
 	go pl.loop()
 	return pl
 }
@@ -93,55 +107,175 @@ func (pl *PaymentLogger) LogPayment(req models.PaymentRequest) {
 	}
 }
 
+// LogPaymentSync inserts req and returns once the row is committed, for
+// config.DurableAccept. Unlike LogPayment it fails when logging is disabled.
+func (pl *PaymentLogger) LogPaymentSync(ctx context.Context, req models.PaymentRequest) error {
+	if pl == nil {
+		return errors.New("payment logging disabled, POSTGRES_DSN not set")
+	}
+	tag, err := pl.pool.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor, status) VALUES ($1,$2,$3,$4) ON CONFLICT DO NOTHING",
+		req.CorrelationID, req.Amount, req.Processor, models.StatusReceived)
+	pl.storeRawPayloads(ctx, []models.PaymentRequest{req})
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		// The ID is already stored; only a different amount is an error.
+		return pl.CheckConflict(ctx, req)
+	}
+	return nil
+}
+
+// CheckConflict fails with errAmountConflict when req's correlation ID is
+// already stored with a different amount, recording the conflict in
+// payment_conflicts. It is nil when logging is disabled.
+func (pl *PaymentLogger) CheckConflict(ctx context.Context, req models.PaymentRequest) error {
+	if pl == nil {
+		return nil
+	}
+	var text *string
+	err := pl.pool.QueryRow(ctx, "SELECT amount::text FROM payments WHERE correlation_id=$1 LIMIT 1", req.CorrelationID).Scan(&text)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && text == nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	existing := models.Amount(*text)
+	if existing.Equal(req.Amount) {
+		return nil
+	}
+	logging.Warnf("Gateway: Conflict for correlation ID %s: already stored with amount %s, got %s",
+		req.CorrelationID, existing, req.Amount)
+	if _, err := pl.pool.Exec(ctx, "INSERT INTO payment_conflicts (correlation_id, existing_amount, conflicting_amount) VALUES ($1,$2,$3)",
+		req.CorrelationID, existing, req.Amount); err != nil {
+		logging.Errorf("Gateway: Error recording conflict for %s: %v", req.CorrelationID, err)
+	}
+	return errAmountConflict
+}
+
+// Close flushes the pending batch and closes the pool.
 func (pl *PaymentLogger) Close() {
 	if pl == nil {
 		return
 	}
 	pl.cancel()
+	<-pl.done
 	pl.pool.Close()
 }
 
+// loop accumulates batches and hands each full (or timed-out) one to the
+// flush workers, so the next batch fills while earlier ones are written. When
+// all config.LoggerFlushWorkers are busy the hand-off blocks, which bounds the
+// concurrent flushes and lets the channel buffer absorb the wait.
 func (pl *PaymentLogger) loop() {
-	ticker := time.NewTicker(flushInterval)
-	defer ticker.Stop()
+	defer close(pl.done)
+	batches := make(chan []models.PaymentRequest)
+	var flushers sync.WaitGroup
+	flushers.Add(pl.flushWorkers)
+	for i := 0; i < pl.flushWorkers; i++ {
+		go func() {
+			defer flushers.Done()
+			for batch := range batches {
+				pl.flush(batch)
+			}
+		}()
+	}
+	defer flushers.Wait()
+	defer close(batches)
 
-	batch := make([]models.PaymentRequest, 0, batchSize)
+	ticker := time.NewTicker(pl.flushInterval)
+	defer ticker.Stop()
 
-	flush := func() {
+	batch := make([]models.PaymentRequest, 0, pl.batchSize)
+	handOff := func() {
 		if len(batch) == 0 {
 			return
 		}
-		// Build COPY ... or INSERT ... VALUES batch
-		// For simplicity and because < 256 rows, use INSERT.
-		// Build args slice.
-		var sql string = "INSERT INTO payments (correlation_id, amount, processor) VALUES "
-		args := make([]interface{}, 0, len(batch)*3)
-		for i, p := range batch {
-			if i > 0 {
-				sql += ","
-			}
-			sql += fmt.Sprintf("($%d,$%d,$%d)", i*3+1, i*3+2, i*3+3)
-			args = append(args, p.CorrelationID, p.Amount, p.Processor)
-		}
-		sql += " ON CONFLICT DO NOTHING"
-		if _, err := pl.pool.Exec(pl.ctx, sql, args...); err != nil {
-			log.Printf("PaymentLogger: insert batch err: %v", err)
-		}
-		batch = batch[:0]
+		batches <- batch
+		batch = make([]models.PaymentRequest, 0, pl.batchSize)
 	}
 
 	for {
 		select {
 		case <-pl.ctx.Done():
-			flush()
+			// Hand off whatever is still buffered in the channel; the deferred
+			// Wait lets the flush workers finish before done is closed.
+			for n := len(pl.ch); n > 0; n-- {
+				batch = append(batch, <-pl.ch)
+				if len(batch) >= pl.batchSize {
+					handOff()
+				}
+			}
+			handOff()
 			return
 		case req := <-pl.ch:
 			batch = append(batch, req)
-			if len(batch) >= batchSize {
-				flush()
+			if len(batch) >= pl.batchSize {
+				handOff()
 			}
 		case <-ticker.C:
-			flush()
+			handOff()
+		}
+	}
+}
+
+// flush inserts batch in one statement. Rows are marked received; the worker
+// upgrades them once processed. Each flush has its own deadline rather than
+// pl.ctx, so one still running when Close is called is not cut short.
+func (pl *PaymentLogger) flush(batch []models.PaymentRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var sql strings.Builder
+	sql.WriteString("INSERT INTO payments (correlation_id, amount, processor, status) VALUES ")
+	args := make([]interface{}, 0, len(batch)*3)
+	for i, p := range batch {
+		if i > 0 {
+			sql.WriteString(",")
+		}
+		fmt.Fprintf(&sql, "($%d,$%d,$%d,'%s')", i*3+1, i*3+2, i*3+3, models.StatusReceived)
+		args = append(args, p.CorrelationID, p.Amount, p.Processor)
+	}
+	sql.WriteString(" ON CONFLICT DO NOTHING")
+	tag, err := pl.pool.Exec(ctx, sql.String(), args...)
+	pl.storeRawPayloads(ctx, batch)
+	if err != nil {
+		logging.Errorf("PaymentLogger: insert batch err: %v", err)
+		return
+	}
+	pl.countBatch(len(batch), tag.RowsAffected())
+}
+
+// storeRawPayloads copies the raw bodies of batch into raw_payloads. Every
+// body is kept as received, whether or not its payments row was inserted:
+// duplicates and rows whose insert failed included, so a replay re-posts
+// the traffic the gateway saw. Losing them only affects replay, so errors
+// are logged and not returned.
+func (pl *PaymentLogger) storeRawPayloads(ctx context.Context, batch []models.PaymentRequest) {
+	rows := make([][]any, 0, len(batch))
+	for _, p := range batch {
+		if p.RawBody != nil {
+			rows = append(rows, []any{p.CorrelationID, p.RawBody})
 		}
 	}
+	if len(rows) == 0 {
+		return
+	}
+	if _, err := pl.pool.CopyFrom(ctx, pgx.Identifier{"raw_payloads"}, []string{"correlation_id", "body"}, pgx.CopyFromRows(rows)); err != nil {
+		logging.Errorf("PaymentLogger: storing %d raw payloads failed: %v", len(rows), err)
+	}
+}
+
+// countBatch accounts for a flushed batch and reports duplicates, which
+// should be rare: a client retrying a payment without an Idempotency-Key.
+// Flush workers call it concurrently.
+func (pl *PaymentLogger) countBatch(submitted int, inserted int64) {
+	totalSubmitted := pl.submitted.Add(int64(submitted))
+	totalInserted := pl.inserted.Add(inserted)
+	if skipped := int64(submitted) - inserted; skipped > 0 {
+		logging.Warnf("PaymentLogger: batch inserted %d/%d rows, %d duplicates (total %d of %d submitted skipped)",
+			inserted, submitted, skipped, totalSubmitted-totalInserted, totalSubmitted)
+		return
+	}
+	logging.Debugf("PaymentLogger: batch inserted %d rows", inserted)
 }
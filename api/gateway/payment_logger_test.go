@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+	"rinha-backend-golang/paymentspb"
+)
+
+// testLogger returns a PaymentLogger on the database named by
+// TEST_POSTGRES_DSN with empty payments tables, skipping the test when it is
+// not set. Only the synchronous methods are usable; no flush loop runs.
+func testLogger(tb testing.TB) *PaymentLogger {
+	tb.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(pool.Close)
+	config.RunMigrations = true
+	if err := config.EnsureSchema(ctx, pool); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, "TRUNCATE payments, payment_conflicts, raw_payloads"); err != nil {
+		tb.Fatal(err)
+	}
+	return &PaymentLogger{pool: pool}
+}
+
+const conflictID = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+
+func TestDurableAcceptRejectsDifferentAmount(t *testing.T) {
+	pl := testLogger(t)
+	defer func(durable bool) { config.DurableAccept = durable }(config.DurableAccept)
+	defer func(n int) { config.CorrelationIDMaxLen = n }(config.CorrelationIDMaxLen)
+	config.DurableAccept = true
+	config.CorrelationIDMaxLen = 36
+
+	ctx := context.Background()
+	first := models.PaymentRequest{CorrelationID: conflictID, Amount: "10.00"}
+	if err := pl.LogPaymentSync(ctx, first); err != nil {
+		t.Fatalf("first payment: %v", err)
+	}
+	// The same amount written differently is a plain duplicate.
+	if err := pl.LogPaymentSync(ctx, models.PaymentRequest{CorrelationID: conflictID, Amount: "10.0"}); err != nil {
+		t.Fatalf("same amount: %v", err)
+	}
+
+	api := &APIGateway{logger: pl}
+	rec := httptest.NewRecorder()
+	body := `{"correlationId":"` + conflictID + `","amount":20.00}`
+	api.handlePayments(rec, httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// gRPC clients are told not to retry it.
+	_, err := grpcClient(t, api).SubmitPayment(ctx, &paymentspb.SubmitPaymentRequest{CorrelationId: conflictID, Amount: "20.00"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("SubmitPayment = %v, want %s", err, codes.AlreadyExists)
+	}
+
+	var existing, conflicting string
+	err = pl.pool.QueryRow(ctx, "SELECT existing_amount::text, conflicting_amount::text FROM payment_conflicts WHERE correlation_id=$1",
+		conflictID).Scan(&existing, &conflicting)
+	if err != nil {
+		t.Fatalf("conflict not recorded: %v", err)
+	}
+	if !models.Amount(existing).Equal("10") || !models.Amount(conflicting).Equal("20") {
+		t.Errorf("recorded amounts %s and %s, want 10 and 20", existing, conflicting)
+	}
+}
@@ -0,0 +1,195 @@
+package gateway
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"rinha-backend-golang/models"
+)
+
+// paymentQueue holds accepted payments until a forwarder takes them. Pushes
+// must not race with close; APIGateway serialises them with queueMu.
+type paymentQueue interface {
+	// tryPush queues req unless the queue is full.
+	tryPush(req models.PaymentRequest) bool
+	// pushWait waits for room until the timer fires or ctx is done.
+	pushWait(ctx context.Context, timer <-chan time.Time, req models.PaymentRequest) bool
+	// evict drops the payment that would be forwarded last, if any.
+	evict() (models.PaymentRequest, bool)
+	// pop blocks until a payment is queued, reporting false once the queue
+	// is closed and empty.
+	pop() (models.PaymentRequest, bool)
+	len() int
+	close()
+}
+
+// newPaymentQueue returns the queue for config.QueueOrder, holding up to size
+// payments.
+func newPaymentQueue(order string, size int) paymentQueue {
+	if order == "deadline" {
+		return newDeadlineQueue(size)
+	}
+	return fifoQueue(make(chan models.PaymentRequest, size))
+}
+
+// fifoQueue forwards payments in arrival order.
+type fifoQueue chan models.PaymentRequest
+
+func (q fifoQueue) tryPush(req models.PaymentRequest) bool {
+	select {
+	case q <- req:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q fifoQueue) pushWait(ctx context.Context, timer <-chan time.Time, req models.PaymentRequest) bool {
+	select {
+	case q <- req:
+		return true
+	case <-timer:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (q fifoQueue) evict() (models.PaymentRequest, bool) {
+	select {
+	case req := <-q:
+		return req, true
+	default:
+		return models.PaymentRequest{}, false
+	}
+}
+
+func (q fifoQueue) pop() (models.PaymentRequest, bool) {
+	req, ok := <-q
+	return req, ok
+}
+
+func (q fifoQueue) len() int { return len(q) }
+func (q fifoQueue) close()   { close(q) }
+
+// deadlineQueue forwards the payment closest to its client deadline first,
+// so under a backlog as many payments as possible reach a worker in time.
+// Payments without a deadline follow all those with one, in arrival order.
+// The forwarders drop payments already past their deadline when they pop
+// them. slots and ready count free and queued places, so waiting for either
+// works with select like the channel of fifoQueue.
+type deadlineQueue struct {
+	mu    sync.Mutex
+	items deadlineHeap
+	seq   uint64
+	slots chan struct{} // one token per occupied place
+	ready chan struct{} // one token per queued payment
+}
+
+func newDeadlineQueue(size int) *deadlineQueue {
+	return &deadlineQueue{
+		slots: make(chan struct{}, size),
+		ready: make(chan struct{}, size),
+	}
+}
+
+func (q *deadlineQueue) tryPush(req models.PaymentRequest) bool {
+	select {
+	case q.slots <- struct{}{}:
+		q.add(req)
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *deadlineQueue) pushWait(ctx context.Context, timer <-chan time.Time, req models.PaymentRequest) bool {
+	select {
+	case q.slots <- struct{}{}:
+		q.add(req)
+		return true
+	case <-timer:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// add queues req in a slot already taken.
+func (q *deadlineQueue) add(req models.PaymentRequest) {
+	q.mu.Lock()
+	q.seq++
+	heap.Push(&q.items, deadlineItem{req: req, seq: q.seq})
+	q.mu.Unlock()
+	q.ready <- struct{}{}
+}
+
+func (q *deadlineQueue) evict() (models.PaymentRequest, bool) {
+	select {
+	case <-q.ready:
+	default:
+		return models.PaymentRequest{}, false
+	}
+	q.mu.Lock()
+	// The last item to forward is one of the leaves; a linear scan is fine
+	// for an overflow path.
+	last := 0
+	for i := range q.items {
+		if q.items.Less(last, i) {
+			last = i
+		}
+	}
+	item := heap.Remove(&q.items, last).(deadlineItem)
+	q.mu.Unlock()
+	<-q.slots
+	return item.req, true
+}
+
+func (q *deadlineQueue) pop() (models.PaymentRequest, bool) {
+	if _, ok := <-q.ready; !ok {
+		return models.PaymentRequest{}, false
+	}
+	q.mu.Lock()
+	item := heap.Pop(&q.items).(deadlineItem)
+	q.mu.Unlock()
+	<-q.slots
+	return item.req, true
+}
+
+func (q *deadlineQueue) len() int { return len(q.ready) }
+
+// close lets pop drain the queued payments, then report false.
+func (q *deadlineQueue) close() { close(q.ready) }
+
+type deadlineItem struct {
+	req models.PaymentRequest
+	seq uint64 // arrival order, breaking ties
+}
+
+// deadlineHeap is a container/heap min-heap by deadline, then arrival.
+type deadlineHeap []deadlineItem
+
+func (h deadlineHeap) Len() int { return len(h) }
+
+func (h deadlineHeap) Less(i, j int) bool {
+	a, b := h[i].req.Deadline, h[j].req.Deadline
+	switch {
+	case a.IsZero() != b.IsZero():
+		return !a.IsZero()
+	case !a.Equal(b):
+		return a.Before(b)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h deadlineHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *deadlineHeap) Push(x any)   { *h = append(*h, x.(deadlineItem)) }
+
+func (h *deadlineHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,153 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+func TestDeadlineQueueOrder(t *testing.T) {
+	now := time.Now()
+	in := func(d time.Duration) time.Time { return now.Add(d) }
+	tests := []struct {
+		name      string
+		deadlines map[string]time.Time // zero for none
+		push      []string
+		want      []string
+	}{
+		{
+			name:      "earliest deadline first",
+			deadlines: map[string]time.Time{"a": in(3 * time.Second), "b": in(time.Second), "c": in(2 * time.Second)},
+			push:      []string{"a", "b", "c"},
+			want:      []string{"b", "c", "a"},
+		},
+		{
+			name:      "no deadline after all with one",
+			deadlines: map[string]time.Time{"b": in(time.Second)},
+			push:      []string{"a", "b", "c"},
+			want:      []string{"b", "a", "c"},
+		},
+		{
+			name:      "equal deadlines in arrival order",
+			deadlines: map[string]time.Time{"a": in(time.Second), "b": in(time.Second), "c": in(time.Second)},
+			push:      []string{"c", "a", "b"},
+			want:      []string{"c", "a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newDeadlineQueue(len(tt.push))
+			for _, id := range tt.push {
+				if !q.tryPush(models.PaymentRequest{CorrelationID: id, Deadline: tt.deadlines[id]}) {
+					t.Fatalf("push %s refused", id)
+				}
+			}
+			if q.tryPush(models.PaymentRequest{CorrelationID: "extra"}) {
+				t.Fatal("push accepted into a full queue")
+			}
+			q.close()
+			var got []string
+			for {
+				req, ok := q.pop()
+				if !ok {
+					break
+				}
+				got = append(got, req.CorrelationID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("popped %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("popped %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDeadlineQueueEvictsLast(t *testing.T) {
+	now := time.Now()
+	q := newDeadlineQueue(3)
+	q.tryPush(models.PaymentRequest{CorrelationID: "soon", Deadline: now.Add(time.Second)})
+	q.tryPush(models.PaymentRequest{CorrelationID: "none"})
+	q.tryPush(models.PaymentRequest{CorrelationID: "later", Deadline: now.Add(time.Minute)})
+	req, ok := q.evict()
+	if !ok || req.CorrelationID != "none" {
+		t.Fatalf("evict = %q, %v; want none", req.CorrelationID, ok)
+	}
+	if !q.tryPush(models.PaymentRequest{CorrelationID: "new"}) {
+		t.Error("evict did not free a place")
+	}
+	if req, _ := q.pop(); req.CorrelationID != "soon" {
+		t.Errorf("pop = %q, want soon", req.CorrelationID)
+	}
+}
+
+func TestForwardDropsExpired(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Time
+		wantHits int64
+	}{
+		{"past deadline dropped", time.Now().Add(-time.Millisecond), 0},
+		{"within deadline forwarded", time.Now().Add(time.Minute), 1},
+		{"no deadline forwarded", time.Time{}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := workerStub(t, http.StatusOK)
+			api := &APIGateway{httpClient: &http.Client{}, workers: newWorkerPool([]string{srv.URL})}
+			api.forwardOne(models.PaymentRequest{CorrelationID: "p1", Amount: "1", Deadline: tt.deadline})
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("worker received %d forwards, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+// TestEnqueueDropOldest saturates a FIFO queue and checks that with
+// QUEUE_DROP_OLDEST the newest payments are the ones kept.
+func TestEnqueueDropOldest(t *testing.T) {
+	defer func(drop bool) { config.QueueDropOldest = drop }(config.QueueDropOldest)
+	tests := []struct {
+		dropOldest  bool
+		want        []string
+		wantEvicted int64
+	}{
+		{false, []string{"p0", "p1", "p2"}, 0},
+		{true, []string{"p7", "p8", "p9"}, 7},
+	}
+	for _, tt := range tests {
+		config.QueueDropOldest = tt.dropOldest
+		api := &APIGateway{paymentQueue: newPaymentQueue("fifo", 3)}
+		api.accepting.Store(true)
+		for i := 0; i < 10; i++ {
+			id := fmt.Sprintf("p%d", i)
+			if ok := api.enqueue(context.Background(), models.PaymentRequest{CorrelationID: id}); ok != (i < 3 || tt.dropOldest) {
+				t.Errorf("QUEUE_DROP_OLDEST=%t: enqueue %s = %t", tt.dropOldest, id, ok)
+			}
+		}
+		api.paymentQueue.close()
+		var got []string
+		for {
+			req, ok := api.paymentQueue.pop()
+			if !ok {
+				break
+			}
+			got = append(got, req.CorrelationID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("QUEUE_DROP_OLDEST=%t: queue holds %v, want %v", tt.dropOldest, got, tt.want)
+		}
+		if n := api.forwardStats.evicted.Load(); n != tt.wantEvicted {
+			t.Errorf("QUEUE_DROP_OLDEST=%t: %d evictions counted, want %d", tt.dropOldest, n, tt.wantEvicted)
+		}
+	}
+}
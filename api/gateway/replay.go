@@ -0,0 +1,59 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// Replay re-posts every body stored in raw_payloads (STORE_RAW_PAYLOADS), in
+// the order they were received, to target's /payments exactly as the client
+// sent it. It is what MODE=replay runs. A failed POST is logged and counted;
+// only a database error stops the replay.
+func Replay(ctx context.Context, pool *pgxpool.Pool, target string) error {
+	if pool == nil {
+		return errors.New("replay requires POSTGRES_DSN")
+	}
+	rows, err := pool.Query(ctx, "SELECT correlation_id, body FROM raw_payloads ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	statuses := make(map[int]int)
+	failed := 0
+	for rows.Next() {
+		var id string
+		var body []byte
+		if err := rows.Scan(&id, &body); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target+"/payments", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		config.SetOutboundHeaders(req.Header)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			logging.Warnf("Replay: payment %s not delivered: %v", id, err)
+			failed++
+			continue
+		}
+		resp.Body.Close()
+		statuses[resp.StatusCode]++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	logging.Infof("Replay: re-posted payloads to %s, responses by status %v, %d not delivered", target, statuses, failed)
+	return nil
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+// TestRawPayloadRoundTrip stores bodies through both logging paths and
+// checks that Replay re-posts every one of them byte for byte, duplicates
+// included.
+func TestRawPayloadRoundTrip(t *testing.T) {
+	pl := testLogger(t)
+	defer func(raw, durable bool, n int) {
+		config.StoreRawPayloads, config.DurableAccept, config.CorrelationIDMaxLen = raw, durable, n
+	}(config.StoreRawPayloads, config.DurableAccept, config.CorrelationIDMaxLen)
+	config.StoreRawPayloads, config.DurableAccept, config.CorrelationIDMaxLen = true, true, 36
+
+	const (
+		id1 = "0b6c3f52-8d8e-4f0e-9a51-6f4b1d2c3e01"
+		id2 = "0b6c3f52-8d8e-4f0e-9a51-6f4b1d2c3e02"
+		id3 = "0b6c3f52-8d8e-4f0e-9a51-6f4b1d2c3e03"
+	)
+	// Odd spacing, key order, exponents and unknown fields must survive.
+	first := []byte("{ \"amount\" : 19.900,\n  \"correlationId\":\"" + id1 + "\" }")
+	second := []byte(`{"correlationId":"` + id2 + `","amount":1e1,"note":"ç"}`)
+	batched := []byte(`{"correlationId":"` + id3 + `","amount":5}`)
+	batchedDup := []byte(`{"amount":19.9,"correlationId":"` + id1 + `"}`)
+
+	api := &APIGateway{logger: pl, paymentQueue: newPaymentQueue("", 10)}
+	api.accepting.Store(true)
+	for _, body := range [][]byte{first, second, first} {
+		rec := httptest.NewRecorder()
+		api.handlePayments(rec, httptest.NewRequest(http.MethodPost, "/payments", bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s = %d: %s", body, rec.Code, rec.Body)
+		}
+	}
+	pl.flush([]models.PaymentRequest{
+		{CorrelationID: id3, Amount: "5", RawBody: batched},
+		{CorrelationID: id1, Amount: "19.9", RawBody: batchedDup},
+	})
+
+	var mu sync.Mutex
+	var replayed [][]byte
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		replayed = append(replayed, body)
+		mu.Unlock()
+	}))
+	defer target.Close()
+	if err := Replay(context.Background(), pl.pool, target.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]byte{first, second, first, batched, batchedDup}
+	if len(replayed) != len(want) {
+		t.Fatalf("replayed %d bodies, want %d", len(replayed), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(replayed[i], want[i]) {
+			t.Errorf("body %d replayed as %q, want %q", i, replayed[i], want[i])
+		}
+	}
+}
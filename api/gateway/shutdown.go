@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// server is what serve runs: an *http.Server, or the gRPC server when
+// config.Protocol is "grpc".
+type server interface {
+	ListenAndServe() error
+	Shutdown(ctx context.Context) error
+}
+
+// serve runs srv until SIGTERM or SIGINT, then shuts the gateway down:
+// stop accepting payments, let the forwarders drain the queue within
+// config.ShutdownGrace, flush the payment log and, in combined mode, drain
+// the embedded worker.
+func (api *APIGateway) serve(srv server) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	logging.Infof("Gateway: shutting down, %d payments queued, grace period %s", api.paymentQueue.len(), config.ShutdownGrace)
+	graceCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownGrace)
+	defer cancel()
+	api.closeQueue()
+	if err := srv.Shutdown(graceCtx); err != nil {
+		logging.Warnf("Gateway: HTTP shutdown: %v", err)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		api.forwarders.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-graceCtx.Done():
+		logging.Warnf("Gateway: grace period over with %d payments still queued", api.paymentQueue.len())
+	}
+	api.logger.Close()
+	if api.local != nil {
+		api.local.Drain()
+	}
+}
+
+// closeQueue stops accepting payments and closes paymentQueue so the
+// forwarders exit once it is empty. The write lock waits for any enqueue
+// in progress, so no push can hit the closed queue.
+func (api *APIGateway) closeQueue() {
+	api.accepting.Store(false)
+	api.queueMu.Lock()
+	api.paymentQueue.close()
+	api.queueMu.Unlock()
+}
+
+// requeue puts back a payment the worker was too busy to take, reporting
+// false when the queue is full or already closed.
+func (api *APIGateway) requeue(req models.PaymentRequest) bool {
+	api.queueMu.RLock()
+	defer api.queueMu.RUnlock()
+	if !api.accepting.Load() {
+		return false
+	}
+	return api.paymentQueue.tryPush(req)
+}
+
+// forwardBusy keeps retrying a payment the worker refused while the queue is
+// closed for shutdown, since it can no longer be re-queued.
+func (api *APIGateway) forwardBusy(req models.PaymentRequest) {
+	for attempt := 1; attempt <= 5; attempt++ {
+		time.Sleep(backoff.Delay(attempt, workerBusyBackoff, 500*time.Millisecond))
+		if err := api.forwardPayment(req); err == nil {
+			return
+		}
+	}
+	logging.Warnf("Gateway: dropping payment %s, worker still busy during shutdown", req.CorrelationID)
+}
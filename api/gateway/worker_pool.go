@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+// workerTarget is one worker the gateway forwards to.
+type workerTarget struct {
+	url         string
+	outstanding atomic.Int64 // forwards in flight
+	failedUntil atomic.Int64 // unix nanos; skipped until then after a failure
+}
+
+// workerPool balances forwards across config.WorkerURLs, round-robin or to
+// the worker with the fewest outstanding forwards (config.WorkerBalance).
+// Workers that recently failed are skipped for config.WorkerFailureCooldown;
+// when every worker is cooling down they are all tried again, since a
+// forward to a possibly failing worker beats not forwarding at all.
+type workerPool struct {
+	targets []*workerTarget
+	next    atomic.Uint64
+	least   bool
+}
+
+func newWorkerPool(urls []string) *workerPool {
+	p := &workerPool{least: config.WorkerBalance == "least-outstanding"}
+	for _, u := range urls {
+		p.targets = append(p.targets, &workerTarget{url: u})
+	}
+	return p
+}
+
+// acquire picks the worker for the next forward and counts it as
+// outstanding; the caller reports the outcome with release.
+func (p *workerPool) acquire() *workerTarget {
+	t := p.pick(time.Now().UnixNano())
+	t.outstanding.Add(1)
+	return t
+}
+
+func (p *workerPool) pick(now int64) *workerTarget {
+	if len(p.targets) == 1 {
+		return p.targets[0]
+	}
+	start := int(p.next.Add(1) % uint64(len(p.targets)))
+	var best *workerTarget
+	for i := range p.targets {
+		t := p.targets[(start+i)%len(p.targets)]
+		if t.failedUntil.Load() > now {
+			continue
+		}
+		if !p.least {
+			return t
+		}
+		if best == nil || t.outstanding.Load() < best.outstanding.Load() {
+			best = t
+		}
+	}
+	if best == nil {
+		return p.targets[start]
+	}
+	return best
+}
+
+// release ends a forward to t. A failed one takes t out of rotation for the
+// cooldown; a successful one puts it back right away.
+func (p *workerPool) release(t *workerTarget, failed bool) {
+	t.outstanding.Add(-1)
+	if failed {
+		t.failedUntil.Store(time.Now().Add(config.WorkerFailureCooldown).UnixNano())
+	} else if t.failedUntil.Load() != 0 {
+		t.failedUntil.Store(0)
+	}
+}
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+// workerStub is a worker's /process-payment answering with a fixed status.
+func workerStub(t *testing.T, status int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestForwardAcrossWorkerPool(t *testing.T) {
+	defer func(balance string, cooldown time.Duration) {
+		config.WorkerBalance, config.WorkerFailureCooldown = balance, cooldown
+	}(config.WorkerBalance, config.WorkerFailureCooldown)
+	config.WorkerFailureCooldown = time.Minute
+
+	tests := []struct {
+		name              string
+		balance           string
+		statusA, statusB  int
+		wantA, wantB      int64
+		wantForwardErrors int
+	}{
+		{"round-robin spreads evenly", "round-robin", http.StatusOK, http.StatusOK, 5, 5, 0},
+		{"least-outstanding spreads evenly", "least-outstanding", http.StatusOK, http.StatusOK, 5, 5, 0},
+		{"failing worker skipped", "round-robin", http.StatusOK, http.StatusInternalServerError, 9, 1, 1},
+		{"busy worker stays in rotation", "round-robin", http.StatusOK, http.StatusServiceUnavailable, 5, 5, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.WorkerBalance = tt.balance
+			a, hitsA := workerStub(t, tt.statusA)
+			b, hitsB := workerStub(t, tt.statusB)
+			api := &APIGateway{httpClient: &http.Client{}, workers: newWorkerPool([]string{a.URL, b.URL})}
+
+			errs := 0
+			for i := 0; i < 10; i++ {
+				if api.forwardPayment(models.PaymentRequest{CorrelationID: fmt.Sprint(i), Amount: "1"}) != nil {
+					errs++
+				}
+			}
+			if hitsA.Load() != tt.wantA || hitsB.Load() != tt.wantB {
+				t.Errorf("forwards = %d and %d, want %d and %d", hitsA.Load(), hitsB.Load(), tt.wantA, tt.wantB)
+			}
+			if errs != tt.wantForwardErrors {
+				t.Errorf("failed forwards = %d, want %d", errs, tt.wantForwardErrors)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolAllCoolingDown(t *testing.T) {
+	defer func(cooldown time.Duration) { config.WorkerFailureCooldown = cooldown }(config.WorkerFailureCooldown)
+	config.WorkerFailureCooldown = time.Minute
+	p := newWorkerPool([]string{"http://a", "http://b"})
+	p.release(p.acquire(), true)
+	p.release(p.acquire(), true)
+	for _, target := range p.targets {
+		if target.failedUntil.Load() == 0 {
+			t.Fatalf("worker %s not cooling down after a failure", target.url)
+		}
+	}
+
+	// Every worker cooling down: one is still picked, and a success puts
+	// it back in rotation.
+	target := p.acquire()
+	p.release(target, false)
+	if target.failedUntil.Load() != 0 {
+		t.Errorf("worker %s still cooling down after a success", target.url)
+	}
+}
@@ -0,0 +1,99 @@
+// Package logging is a minimal leveled wrapper around the standard logger.
+// Per-payment chatter is logged at debug so it can be silenced under load
+// (LOG_LEVEL=error) without touching the call sites, or sampled with
+// LOG_SAMPLE_RATE.
+package logging
+
+import (
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var current atomic.Int32
+
+// SetLevel sets the minimum level that is written.
+func SetLevel(l Level) {
+	current.Store(int32(l))
+}
+
+// ParseLevel maps debug|info|warn|error (case-insensitive) to a Level.
+func ParseLevel(s string) (Level, bool) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warn", "warning":
+		return LevelWarn, true
+	case "error":
+		return LevelError, true
+	}
+	return LevelDebug, false
+}
+
+// Enabled reports whether messages at l are written.
+func Enabled(l Level) bool {
+	return int32(l) >= current.Load()
+}
+
+func Debugf(format string, args ...any) {
+	if Enabled(LevelDebug) {
+		log.Printf(format, args...)
+	}
+}
+
+func Infof(format string, args ...any) {
+	if Enabled(LevelInfo) {
+		log.Printf(format, args...)
+	}
+}
+
+func Warnf(format string, args ...any) {
+	if Enabled(LevelWarn) {
+		log.Printf(format, args...)
+	}
+}
+
+func Errorf(format string, args ...any) {
+	if Enabled(LevelError) {
+		log.Printf(format, args...)
+	}
+}
+
+var sampleN atomic.Int64
+
+// SetSampleRate makes Paymentf write the lines of 1 in n payments at info
+// level when debug is off. n <= 0 disables sampling.
+func SetSampleRate(n int) {
+	sampleN.Store(int64(n))
+}
+
+// Paymentf logs a per-payment line. At debug level every line is written;
+// otherwise only lines of sampled payments are. The choice hashes the
+// correlation ID, so a sampled payment keeps all of its lines.
+func Paymentf(correlationID string, format string, args ...any) {
+	if Enabled(LevelDebug) {
+		log.Printf(format, args...)
+		return
+	}
+	n := sampleN.Load()
+	if n <= 0 || !Enabled(LevelInfo) {
+		return
+	}
+	h := fnv.New32a()
+	h.Write([]byte(correlationID))
+	if int64(h.Sum32())%n == 0 {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+// paymentLines logs two lines for each of n payments and returns how many
+// were written per correlation ID.
+func paymentLines(t *testing.T, n int) map[string]int {
+	t.Helper()
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(flags)
+
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("payment-%d", i)
+		Paymentf(id, "%s received", id)
+		Paymentf(id, "%s processed", id)
+	}
+	lines := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if id, _, ok := strings.Cut(line, " "); ok {
+			lines[id]++
+		}
+	}
+	return lines
+}
+
+func TestPaymentfSampling(t *testing.T) {
+	defer SetLevel(Level(current.Load()))
+	defer SetSampleRate(int(sampleN.Load()))
+	const payments = 10000
+
+	tests := []struct {
+		name       string
+		level      Level
+		rate       int
+		minSampled int
+		maxSampled int
+	}{
+		{"debug writes every payment", LevelDebug, 10, payments, payments},
+		{"1 in 10 at info", LevelInfo, 10, payments / 10 * 8 / 10, payments / 10 * 12 / 10},
+		{"1 in 100 at info", LevelInfo, 100, payments / 100 * 6 / 10, payments / 100 * 14 / 10},
+		{"sampling off", LevelInfo, 0, 0, 0},
+		{"info disabled", LevelWarn, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetLevel(tt.level)
+			SetSampleRate(tt.rate)
+			lines := paymentLines(t, payments)
+			if n := len(lines); n < tt.minSampled || n > tt.maxSampled {
+				t.Errorf("%d payments logged, want %d to %d", n, tt.minSampled, tt.maxSampled)
+			}
+			for id, n := range lines {
+				if n != 2 {
+					t.Fatalf("%s logged %d of its 2 lines", id, n)
+				}
+			}
+		})
+	}
+}
@@ -1,21 +1,55 @@
 package main
 
 import (
+	"context"
 	"os"
 
 	"rinha-backend-golang/config"
 	"rinha-backend-golang/gateway"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+	"rinha-backend-golang/profiling"
 	"rinha-backend-golang/worker"
 )
 
 func main() {
 	config.Init()
 	mode := os.Getenv("MODE")
-	if mode == "worker" {
+	if !config.Preflight(mode) {
+		os.Exit(1)
+	}
+	profiling.Start(config.PprofAddr)
+	switch mode {
+	case "replay":
+		if err := gateway.Replay(context.Background(), config.PostgresPool, config.ReplayTargetURL); err != nil {
+			logging.Errorf("Replay failed: %v", err)
+			os.Exit(1)
+		}
+	case "worker":
 		workerService := worker.NewWorker()
 		workerService.Start()
-	} else {
+	case "combined":
+		// Single node: the gateway calls the worker directly, no HTTP hop.
+		workerService := worker.NewWorker()
+		workerService.StartBackground()
+		apiGateway := gateway.NewAPIGateway()
+		apiGateway.EmbedWorker(workerService)
+		setSummarySource(apiGateway)
+		apiGateway.Start()
+	default:
 		apiGateway := gateway.NewAPIGateway()
+		setSummarySource(apiGateway)
 		apiGateway.Start()
 	}
 }
+
+// setSummarySource lets the gateway's GetSummary RPC read the payments table
+// directly, since the worker's /payments-summary is HTTP only.
+func setSummarySource(api *gateway.APIGateway) {
+	if config.PostgresPool == nil {
+		return
+	}
+	api.SetSummarySource(func(ctx context.Context) (models.PaymentSummaryResponse, error) {
+		return worker.QuerySummary(ctx, config.PostgresReadPool)
+	})
+}
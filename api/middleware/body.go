@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"rinha-backend-golang/logging"
+)
+
+// maxBufferedBody caps the body BodyReadTimeout buffers; a payment body is a
+// few dozen bytes.
+const maxBufferedBody = 16 << 10
+
+// BodyReadTimeout reads the request body before calling h, giving the client
+// d to send it and answering 408 otherwise, so one dribbling a body cannot
+// hold the handler for its whole budget. Bodies over maxBufferedBody are
+// answered with 413 rather than buffered. It must wrap Timeout rather than be
+// wrapped by it: Timeout's ResponseWriter cannot set deadlines, and the
+// failed read cancels the request context, which Timeout answers with 503.
+func BodyReadTimeout(d time.Duration, h http.Handler) http.Handler {
+	if d <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(time.Now().Add(d)); err != nil {
+			logging.Debugf("Could not set body read deadline: %v", err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBufferedBody))
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			http.Error(w, "Request body not received in time", http.StatusRequestTimeout)
+			return
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rc.SetReadDeadline(time.Time{})
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoBody answers 200 with the body it was given.
+var echoBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Write(body)
+})
+
+func TestBodyReadTimeout(t *testing.T) {
+	srv := httptest.NewServer(BodyReadTimeout(100*time.Millisecond, echoBody))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		length     int    // Content-Length sent
+		body       string // bytes actually sent before waiting for the answer
+		wantStatus int
+		wantBody   string
+	}{
+		{"complete body", 13, `{"amount":10}`, http.StatusOK, `{"amount":10}`},
+		{"slow body", 13, `{"amo`, http.StatusRequestTimeout, ""},
+		{"oversized body", maxBufferedBody + 1, strings.Repeat("x", maxBufferedBody+1), http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(2 * time.Second))
+			fmt.Fprintf(conn, "POST /payments HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", tt.length, tt.body)
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody != "" {
+				if body, _ := io.ReadAll(resp.Body); string(body) != tt.wantBody {
+					t.Errorf("handler got body %q, want %q", body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyReadTimeoutDisabled(t *testing.T) {
+	if h := BodyReadTimeout(0, echoBody); fmt.Sprintf("%p", h) != fmt.Sprintf("%p", echoBody) {
+		t.Error("BodyReadTimeout(0, h) did not return h unchanged")
+	}
+}
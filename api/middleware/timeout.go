@@ -0,0 +1,26 @@
+// Package middleware holds HTTP handler wrappers shared by the gateway and
+// the worker.
+package middleware
+
+import (
+	"net/http"
+
+	"rinha-backend-golang/config"
+)
+
+// Timeout bounds how long the handler registered for pattern may run, using
+// config.HandlerTimeout(pattern). A handler over budget has its request
+// context cancelled and the client gets a 503. Work a handler hands off
+// to a goroutine that does not use the request context is not affected.
+func Timeout(pattern string, h http.Handler) http.Handler {
+	d := config.HandlerTimeout(pattern)
+	if d <= 0 {
+		return h
+	}
+	return http.TimeoutHandler(h, d, "Request timed out")
+}
+
+// HandleFunc registers fn on mux for pattern, wrapped in Timeout.
+func HandleFunc(mux *http.ServeMux, pattern string, fn http.HandlerFunc) {
+	mux.Handle(pattern, Timeout(pattern, fn))
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Amount is a decimal amount kept in its exact textual form. Decoding goes
+// through json.Number instead of float64, so a value such as 0.1 reaches the
+// processors and the NUMERIC column exactly as the client sent it.
+type Amount string
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return fmt.Errorf("amount must be a number")
+	}
+	*a = Amount(n)
+	return nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	if a == "" {
+		return []byte("0"), nil
+	}
+	return []byte(a), nil
+}
+
+// Value stores the amount as text, which Postgres parses into NUMERIC without
+// going through a float.
+func (a Amount) Value() (driver.Value, error) {
+	if a == "" {
+		return nil, nil
+	}
+	return string(a), nil
+}
+
+// Scan reads an amount selected as text (e.g. amount::text).
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = ""
+	case string:
+		*a = Amount(v)
+	case []byte:
+		*a = Amount(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Amount", src)
+	}
+	return nil
+}
+
+// Float64 returns the closest float64, for logging and comparisons where
+// exactness does not matter.
+func (a Amount) Float64() float64 {
+	f, _ := strconv.ParseFloat(string(a), 64)
+	return f
+}
+
+func (a Amount) String() string {
+	if a == "" {
+		return "0"
+	}
+	return string(a)
+}
+
+// Equal compares two amounts exactly, so "10.5" equals "10.50".
+func (a Amount) Equal(b Amount) bool {
+	x, okX := new(big.Rat).SetString(a.String())
+	y, okY := new(big.Rat).SetString(b.String())
+	if !okX || !okY {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAmountKeepsDecimalText(t *testing.T) {
+	tests := []struct {
+		json string
+		want Amount
+	}{
+		// Each of these is rounded by a float64 round trip.
+		{`{"amount":0.1}`, "0.1"},
+		{`{"amount":19.99}`, "19.99"},
+		{`{"amount":1234567890.123456789}`, "1234567890.123456789"},
+		{`{"amount":9007199254740993}`, "9007199254740993"},
+		{`{"amount":10.50}`, "10.50"},
+	}
+	for _, tt := range tests {
+		var req PaymentRequest
+		if err := json.Unmarshal([]byte(tt.json), &req); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", tt.json, err)
+		}
+		if req.Amount != tt.want {
+			t.Errorf("Unmarshal(%s) amount = %q, want %q", tt.json, req.Amount, tt.want)
+		}
+		out, err := json.Marshal(req.Amount)
+		if err != nil || string(out) != string(tt.want) {
+			t.Errorf("Marshal(%q) = %s, %v; want %s", req.Amount, out, err, tt.want)
+		}
+	}
+}
+
+func TestAmountRejectsNonNumbers(t *testing.T) {
+	for _, in := range []string{`"10.00"`, `true`, `[1]`} {
+		var a Amount
+		if err := json.Unmarshal([]byte(in), &a); err == nil {
+			t.Errorf("Unmarshal(%s) = %q, want an error", in, a)
+		}
+	}
+}
+
+func TestAmountEqual(t *testing.T) {
+	tests := []struct {
+		a, b Amount
+		want bool
+	}{
+		{"10.5", "10.50", true},
+		{"0.1", "0.10000", true},
+		{"", "0", true},
+		{"0.1", "0.2", false},
+		{"0.30000000000000004", "0.3", false},
+		{"abc", "abc", true},
+		{"abc", "1", false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.want {
+			t.Errorf("Amount(%q).Equal(%q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
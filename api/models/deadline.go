@@ -0,0 +1,35 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Request headers carrying a client latency budget. DeadlineHeader is an
+// absolute RFC 3339 time; TimeoutHeader is a relative budget in milliseconds.
+// The gateway forwards the resolved deadline to the worker in DeadlineHeader.
+const (
+	DeadlineHeader = "X-Deadline"
+	TimeoutHeader  = "X-Timeout-Ms"
+)
+
+// ParseDeadline resolves the deadline from the header values, preferring the
+// absolute one. A zero time means no deadline was supplied.
+func ParseDeadline(deadline, timeoutMs string, now time.Time) (time.Time, error) {
+	if deadline != "" {
+		t, err := time.Parse(time.RFC3339Nano, deadline)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s: %w", DeadlineHeader, err)
+		}
+		return t, nil
+	}
+	if timeoutMs != "" {
+		ms, err := strconv.Atoi(timeoutMs)
+		if err != nil || ms < 0 {
+			return time.Time{}, fmt.Errorf("invalid %s: %q", TimeoutHeader, timeoutMs)
+		}
+		return now.Add(time.Duration(ms) * time.Millisecond), nil
+	}
+	return time.Time{}, nil
+}
@@ -4,14 +4,46 @@ import "time"
 
 type PaymentRequest struct {
 	CorrelationID string    `json:"correlationId"`
-	Amount        float64   `json:"amount"`
+	Amount        Amount    `json:"amount"`
 	Timestamp     time.Time `json:"timestamp,omitempty"`
 	Processor     string    `json:"processor,omitempty"`
+	Deadline      time.Time `json:"-"` // client deadline, carried in models.DeadlineHeader
+	Attempts      int       `json:"-"` // processing passes already made by the worker
+	PartitionKey  string    `json:"-"` // ordering key, carried in PartitionKeyHeader
+	RawBody       []byte    `json:"-"` // body as received, with STORE_RAW_PAYLOADS
 }
 
+// PartitionKeyHeader lets a client name the key whose payments the worker
+// processes in submission order (with ORDERED_PROCESSING).
+const PartitionKeyHeader = "X-Partition-Key"
+
+// Processing statuses stored in payments.status.
+const (
+	StatusReceived  = "received"  // logged by the gateway, not yet processed
+	StatusProcessed = "processed" // charged by a processor
+	StatusRetrying  = "retrying"  // a processing pass failed, another is scheduled
+	StatusFailed    = "failed"    // every pass failed, dead-lettered
+)
+
 type PaymentSummaryResponse struct {
-	Default  Summary `json:"default"`
-	Fallback Summary `json:"fallback"`
+	Default  Summary  `json:"default"`
+	Fallback Summary  `json:"fallback"`
+	DryRun   *Summary `json:"dryRun,omitempty"` // payments recorded in DRY_RUN mode
+	Other    *Summary `json:"other,omitempty"`  // any other processor value
+
+	ByStatus map[string]Summary `json:"byStatus,omitempty"` // with ?byStatus=true, all rows per status
+
+	// With ?detailed=true, amount statistics of the processed payments per
+	// processor. Rows already pruned by PAYMENT_RETENTION_S are left out.
+	AmountStats map[string]AmountStats `json:"amountStats,omitempty"`
+
+	// With ?rate=true, payments this worker recorded per second over the
+	// last 10 seconds.
+	RatePerSecond *float64 `json:"ratePerSecond,omitempty"`
+
+	// Payment passes this worker started on each processor since it started,
+	// reported when fee schedules or weights drive routing.
+	Routing map[string]int64 `json:"routing,omitempty"`
 }
 
 type Summary struct {
@@ -19,6 +51,115 @@ type Summary struct {
 	TotalAmount   float64 `json:"totalAmount"`
 }
 
+// AmountStats are the smallest, largest and mean amount of a set of payments.
+type AmountStats struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
 type ServiceHealthResponse struct {
 	Failing bool `json:"failing"`
-}
\ No newline at end of file
+}
+type PaymentCountResponse struct {
+	Count int64 `json:"count"`
+}
+
+type MaintenanceResponse struct {
+	Operation  string  `json:"operation"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+type SummarySnapshot struct {
+	TakenAt  time.Time `json:"takenAt"`
+	Default  Summary   `json:"default"`
+	Fallback Summary   `json:"fallback"`
+}
+
+type ThroughputBucket struct {
+	Bucket        time.Time `json:"bucket"`
+	TotalRequests int64     `json:"totalRequests"`
+	TotalAmount   float64   `json:"totalAmount"`
+}
+
+// HourlySummary is the processed payments of one hour, per processor.
+type HourlySummary struct {
+	Hour     time.Time `json:"hour"`
+	Default  Summary   `json:"default"`
+	Fallback Summary   `json:"fallback"`
+}
+
+// ForwardConnStats counts gateway→worker connections by whether they were
+// reused from the idle pool or newly dialed, and queued payments evicted to
+// make room for newer ones.
+type ForwardConnStats struct {
+	Reused  int64 `json:"reused"`
+	New     int64 `json:"new"`
+	Evicted int64 `json:"evicted"`
+}
+
+// InflightCounts is where payments currently are on their way to the
+// payments table, for /debug/inflight. Each service fills in its own stages.
+type InflightCounts struct {
+	Queued        int64 `json:"queued"`        // in the gateway queue
+	Forwarding    int64 `json:"forwarding"`    // taken off the queue, being handed to a worker
+	Processing    int64 `json:"processing"`    // in a worker processing pass
+	Scheduled     int64 `json:"scheduled"`     // waiting for an ordered turn or a retry pass
+	PendingInsert int64 `json:"pendingInsert"` // charged, waiting in a batched insert
+}
+
+// ProcessorErrorCounts counts one processor's failed calls by class, for
+// /processor-errors.
+type ProcessorErrorCounts struct {
+	Timeout     int64 `json:"timeout"`
+	RateLimited int64 `json:"rateLimited"` // 429s and our own PROCESSOR_RATE_LIMIT
+	Unavailable int64 `json:"unavailable"` // transport failures and 5xx
+	Duplicate   int64 `json:"duplicate"`   // 422, the processor already holds the payment
+	Rejected    int64 `json:"rejected"`    // other 4xx
+	BadResponse int64 `json:"badResponse"` // unexpected or declining response body
+	Other       int64 `json:"other"`
+}
+
+// ProcessorStatus is one processor's availability for /status: the polled
+// health and, when enabled, its circuit breaker. Available combines both.
+type ProcessorStatus struct {
+	Healthy         bool           `json:"healthy"`
+	Degraded        bool           `json:"degraded"` // up, but its health poll was slow
+	MinResponseTime int64          `json:"minResponseTime"`
+	Breaker         *BreakerStatus `json:"breaker,omitempty"`
+	Available       bool           `json:"available"`
+}
+
+// BreakerStatus is a processor circuit breaker's state: "closed", "open" or
+// "half-open" (cooldown over, waiting for the next call's outcome).
+type BreakerStatus struct {
+	State        string     `json:"state"`
+	Failures     int        `json:"failures"` // consecutive failed calls
+	TrippedSince *time.Time `json:"trippedSince,omitempty"`
+}
+
+// VerifyResponse compares local totals with each processor's own summary.
+type VerifyResponse struct {
+	Consistent bool         `json:"consistent"`
+	Default    VerifyResult `json:"default"`
+	Fallback   VerifyResult `json:"fallback"`
+}
+
+// VerifyResult is the comparison for one processor. Diffs are local minus
+// processor; Processor is nil and Error set when its summary was unavailable.
+type VerifyResult struct {
+	Local        Summary  `json:"local"`
+	Processor    *Summary `json:"processor,omitempty"`
+	RequestsDiff int64    `json:"requestsDiff"`
+	AmountDiff   float64  `json:"amountDiff"`
+	Match        bool     `json:"match"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// PaymentEvent is one processed payment on /payments/stream.
+type PaymentEvent struct {
+	CorrelationID string    `json:"correlationId"`
+	Processor     string    `json:"processor"`
+	Amount        Amount    `json:"amount"`
+	ProcessedAt   time.Time `json:"processedAt"`
+}
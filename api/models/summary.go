@@ -0,0 +1,18 @@
+package models
+
+import (
+	"strconv"
+
+	"rinha-backend-golang/config"
+)
+
+// MarshalJSON writes TotalAmount with config.SummaryDecimals decimals, so a
+// float sum such as 19.989999999999998 is reported as 19.99, the way the
+// processors report it. The value itself keeps full precision.
+func (s Summary) MarshalJSON() ([]byte, error) {
+	b := []byte(`{"totalRequests":`)
+	b = strconv.AppendInt(b, s.TotalRequests, 10)
+	b = append(b, `,"totalAmount":`...)
+	b = strconv.AppendFloat(b, s.TotalAmount, 'f', config.SummaryDecimals, 64)
+	return append(b, '}'), nil
+}
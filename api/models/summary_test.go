@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"rinha-backend-golang/config"
+)
+
+func TestSummaryMarshalJSON(t *testing.T) {
+	defer func(decimals int) { config.SummaryDecimals = decimals }(config.SummaryDecimals)
+	// A float sum, computed at run time: as a constant it would be exact.
+	a, b := 0.1, 0.2
+	s := Summary{TotalRequests: 2, TotalAmount: a + b}
+
+	tests := []struct {
+		decimals int
+		want     string
+	}{
+		{2, `{"totalRequests":2,"totalAmount":0.30}`},
+		{-1, `{"totalRequests":2,"totalAmount":0.30000000000000004}`},
+	}
+	for _, tt := range tests {
+		config.SummaryDecimals = tt.decimals
+		out, err := json.Marshal(s)
+		if err != nil || string(out) != tt.want {
+			t.Errorf("SUMMARY_DECIMALS=%d: Marshal = %s, %v; want %s", tt.decimals, out, err, tt.want)
+		}
+		// The rounded text is still read back as a Summary.
+		var back Summary
+		if err := json.Unmarshal(out, &back); err != nil || back.TotalRequests != s.TotalRequests {
+			t.Errorf("SUMMARY_DECIMALS=%d: Unmarshal(%s) = %+v, %v", tt.decimals, out, back, err)
+		}
+	}
+}
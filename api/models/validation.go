@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+// FieldError describes one problem with one field of a request.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+type ValidationErrorResponse struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Validate checks every field of the request and returns all problems found,
+// or nil when the request is valid.
+func (p PaymentRequest) Validate() []FieldError {
+	var errs []FieldError
+	switch {
+	case p.CorrelationID == "":
+		errs = append(errs, FieldError{Field: "correlationId", Reason: "is required"})
+	case len(p.CorrelationID) > config.CorrelationIDMaxLen:
+		errs = append(errs, FieldError{Field: "correlationId", Reason: fmt.Sprintf("must be at most %d characters", config.CorrelationIDMaxLen)})
+	case config.CorrelationIDCharset == "token" && !isToken(p.CorrelationID):
+		errs = append(errs, FieldError{Field: "correlationId", Reason: "may only contain letters, digits, '-', '_' and '.'"})
+	case config.CorrelationIDCharset != "token" && !isUUID(p.CorrelationID):
+		errs = append(errs, FieldError{Field: "correlationId", Reason: "must be a UUID"})
+	}
+	// An exponent like 1e999 is valid JSON but overflows to +Inf, which
+	// would poison every SUM over the payments table.
+	switch f := p.Amount.Float64(); {
+	case math.IsNaN(f) || math.IsInf(f, 0):
+		errs = append(errs, FieldError{Field: "amount", Reason: "must be a finite number"})
+	case f <= 0:
+		errs = append(errs, FieldError{Field: "amount", Reason: "must be greater than zero"})
+	}
+	// The timestamp is optional; only a supplied one can be stale.
+	if config.MaxPaymentAge > 0 && !p.Timestamp.IsZero() && time.Since(p.Timestamp) > config.MaxPaymentAge {
+		errs = append(errs, FieldError{Field: "timestamp", Reason: "is older than " + config.MaxPaymentAge.String()})
+	}
+	return errs
+}
+
+// isUUID reports whether s is in the canonical 8-4-4-4-12 hex form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isToken reports whether s only holds ASCII letters, digits, '-', '_' and '.'.
+func isToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
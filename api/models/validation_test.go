@@ -0,0 +1,50 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+func TestValidate(t *testing.T) {
+	defer func(n int, charset string, age time.Duration) {
+		config.CorrelationIDMaxLen, config.CorrelationIDCharset, config.MaxPaymentAge = n, charset, age
+	}(config.CorrelationIDMaxLen, config.CorrelationIDCharset, config.MaxPaymentAge)
+	config.CorrelationIDMaxLen = 36
+	config.MaxPaymentAge = time.Hour
+
+	const uuid = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	tests := []struct {
+		name    string
+		charset string
+		req     PaymentRequest
+		want    []string // fields reported, in order
+	}{
+		{"valid", "uuid", PaymentRequest{CorrelationID: uuid, Amount: "19.90"}, nil},
+		{"missing id", "uuid", PaymentRequest{Amount: "1"}, []string{"correlationId"}},
+		{"id not a uuid", "uuid", PaymentRequest{CorrelationID: "order-1", Amount: "1"}, []string{"correlationId"}},
+		{"token id", "token", PaymentRequest{CorrelationID: "order_1.a-b", Amount: "1"}, nil},
+		{"token id with space", "token", PaymentRequest{CorrelationID: "order 1", Amount: "1"}, []string{"correlationId"}},
+		{"id too long", "token", PaymentRequest{CorrelationID: uuid + "x", Amount: "1"}, []string{"correlationId"}},
+		{"zero amount", "uuid", PaymentRequest{CorrelationID: uuid, Amount: "0"}, []string{"amount"}},
+		{"negative amount", "uuid", PaymentRequest{CorrelationID: uuid, Amount: "-5"}, []string{"amount"}},
+		{"missing amount", "uuid", PaymentRequest{CorrelationID: uuid}, []string{"amount"}},
+		{"overflowing amount", "uuid", PaymentRequest{CorrelationID: uuid, Amount: "1e999"}, []string{"amount"}},
+		{"stale timestamp", "uuid", PaymentRequest{CorrelationID: uuid, Amount: "1", Timestamp: time.Now().Add(-2 * time.Hour)}, []string{"timestamp"}},
+		{"every field", "uuid", PaymentRequest{Amount: "0", Timestamp: time.Now().Add(-2 * time.Hour)}, []string{"correlationId", "amount", "timestamp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.CorrelationIDCharset = tt.charset
+			var got []string
+			for _, e := range tt.req.Validate() {
+				got = append(got, e.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() reported %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
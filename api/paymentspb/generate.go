@@ -0,0 +1,4 @@
+// Package paymentspb holds the gRPC API generated from payments.proto.
+package paymentspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative payments.proto
@@ -0,0 +1,154 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: payments.proto
+
+package paymentspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Payments_SubmitPayment_FullMethodName = "/rinha.payments.v1.Payments/SubmitPayment"
+	Payments_GetSummary_FullMethodName    = "/rinha.payments.v1.Payments/GetSummary"
+)
+
+// PaymentsClient is the client API for Payments service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PaymentsClient interface {
+	// SubmitPayment validates and queues a payment, like POST /payments.
+	// Invalid payments fail with INVALID_ARGUMENT, a full queue with
+	// UNAVAILABLE and an expired call deadline with DEADLINE_EXCEEDED.
+	SubmitPayment(ctx context.Context, in *SubmitPaymentRequest, opts ...grpc.CallOption) (*SubmitPaymentResponse, error)
+	// GetSummary returns the processed payments per processor.
+	GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*PaymentSummary, error)
+}
+
+type paymentsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPaymentsClient(cc grpc.ClientConnInterface) PaymentsClient {
+	return &paymentsClient{cc}
+}
+
+func (c *paymentsClient) SubmitPayment(ctx context.Context, in *SubmitPaymentRequest, opts ...grpc.CallOption) (*SubmitPaymentResponse, error) {
+	out := new(SubmitPaymentResponse)
+	err := c.cc.Invoke(ctx, Payments_SubmitPayment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paymentsClient) GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*PaymentSummary, error) {
+	out := new(PaymentSummary)
+	err := c.cc.Invoke(ctx, Payments_GetSummary_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PaymentsServer is the server API for Payments service.
+// All implementations must embed UnimplementedPaymentsServer
+// for forward compatibility
+type PaymentsServer interface {
+	// SubmitPayment validates and queues a payment, like POST /payments.
+	// Invalid payments fail with INVALID_ARGUMENT, a full queue with
+	// UNAVAILABLE and an expired call deadline with DEADLINE_EXCEEDED.
+	SubmitPayment(context.Context, *SubmitPaymentRequest) (*SubmitPaymentResponse, error)
+	// GetSummary returns the processed payments per processor.
+	GetSummary(context.Context, *GetSummaryRequest) (*PaymentSummary, error)
+	mustEmbedUnimplementedPaymentsServer()
+}
+
+// UnimplementedPaymentsServer must be embedded to have forward compatible implementations.
+type UnimplementedPaymentsServer struct {
+}
+
+func (UnimplementedPaymentsServer) SubmitPayment(context.Context, *SubmitPaymentRequest) (*SubmitPaymentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitPayment not implemented")
+}
+func (UnimplementedPaymentsServer) GetSummary(context.Context, *GetSummaryRequest) (*PaymentSummary, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSummary not implemented")
+}
+func (UnimplementedPaymentsServer) mustEmbedUnimplementedPaymentsServer() {}
+
+// UnsafePaymentsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PaymentsServer will
+// result in compilation errors.
+type UnsafePaymentsServer interface {
+	mustEmbedUnimplementedPaymentsServer()
+}
+
+func RegisterPaymentsServer(s grpc.ServiceRegistrar, srv PaymentsServer) {
+	s.RegisterService(&Payments_ServiceDesc, srv)
+}
+
+func _Payments_SubmitPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitPaymentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentsServer).SubmitPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Payments_SubmitPayment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentsServer).SubmitPayment(ctx, req.(*SubmitPaymentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Payments_GetSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaymentsServer).GetSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Payments_GetSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaymentsServer).GetSummary(ctx, req.(*GetSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Payments_ServiceDesc is the grpc.ServiceDesc for Payments service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Payments_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rinha.payments.v1.Payments",
+	HandlerType: (*PaymentsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitPayment",
+			Handler:    _Payments_SubmitPayment_Handler,
+		},
+		{
+			MethodName: "GetSummary",
+			Handler:    _Payments_GetSummary_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "payments.proto",
+}
@@ -0,0 +1,35 @@
+// Package profiling serves the net/http/pprof handlers on a dedicated admin
+// listener, separate from the public API port.
+package profiling
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"rinha-backend-golang/logging"
+)
+
+// Handler returns a mux exposing the pprof endpoints under /debug/pprof/.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// Start serves the pprof endpoints on addr in the background. An empty addr
+// leaves profiling disabled.
+func Start(addr string) {
+	if addr == "" {
+		return
+	}
+	go func() {
+		logging.Infof("pprof listening on %s", addr)
+		if err := http.ListenAndServe(addr, Handler()); err != nil {
+			logging.Errorf("pprof server stopped: %v", err)
+		}
+	}()
+}
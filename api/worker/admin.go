@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// requireAdmin checks the X-Admin-Token header against config.AdminToken and
+// writes the error response when it does not match. Admin endpoints are
+// refused outright when no token is configured.
+func requireAdmin(wr http.ResponseWriter, r *http.Request) bool {
+	if config.AdminToken == "" {
+		http.Error(wr, "admin endpoints disabled", http.StatusForbidden)
+		return false
+	}
+	token := r.Header.Get("X-Admin-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) != 1 {
+		http.Error(wr, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleVacuum runs VACUUM ANALYZE on the payments table, typically after a
+// large purge. VACUUM cannot run inside a transaction block, so it is issued
+// as a standalone statement on the pool.
+func (w *Worker) handleVacuum(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(wr, r) {
+		return
+	}
+	start := time.Now()
+	if _, err := w.db.Exec(context.Background(), "VACUUM ANALYZE payments"); err != nil {
+		logging.Errorf("Worker: vacuum error: %v", err)
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	resp := models.MaintenanceResponse{
+		Operation:  "vacuum analyze",
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	logging.Infof("Worker: VACUUM ANALYZE payments took %.1fms", resp.DurationMs)
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(resp)
+}
+
+// handleSnapshotReset returns the summary and empties the payments table in
+// one transaction. The ACCESS EXCLUSIVE lock taken up front makes concurrent
+// inserts wait for the commit, so every payment is counted in exactly one
+// window: the returned one or the next.
+func (w *Worker) handleSnapshotReset(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(wr, r) {
+		return
+	}
+	ctx := r.Context()
+	tx, err := w.db.Begin(ctx)
+	if err != nil {
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, "LOCK TABLE payments IN ACCESS EXCLUSIVE MODE"); err != nil {
+		logging.Errorf("Worker: snapshot-reset lock error: %v", err)
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	summary, err := querySummary(ctx, tx, nil, nil)
+	if err != nil {
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(ctx, "TRUNCATE payments, payments_pruned_totals"); err != nil {
+		logging.Errorf("Worker: snapshot-reset truncate error: %v", err)
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logging.Errorf("Worker: snapshot-reset commit error: %v", err)
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	logging.Infof("Worker: summary snapshot taken and payments reset")
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(summary)
+}
+
+// handleProcessorURLs repoints processors at runtime: the JSON body maps
+// "default" and/or "fallback" to a new base URL, used by the next processor
+// call and health poll. It only affects this worker. The response holds the
+// URLs now in use.
+func (w *Worker) handleProcessorURLs(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(wr, r) {
+		return
+	}
+	var urls map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&urls); err != nil || len(urls) == 0 {
+		http.Error(wr, "body must map default and/or fallback to a URL", http.StatusBadRequest)
+		return
+	}
+	for name, u := range urls {
+		if name != "default" && name != "fallback" {
+			http.Error(wr, "unknown processor "+name, http.StatusBadRequest)
+			return
+		}
+		if parsed, err := neturl.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			http.Error(wr, "invalid URL for "+name, http.StatusBadRequest)
+			return
+		}
+		urls[name] = strings.TrimSuffix(u, "/")
+	}
+	config.SetProcessorURLs(urls)
+	logging.Infof("Worker: processor URLs changed: %v", urls)
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(map[string]string{
+		"default":  config.ProcessorURL("default"),
+		"fallback": config.ProcessorURL("fallback"),
+	})
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"rinha-backend-golang/config"
+)
+
+func TestHandleProcessorURLs(t *testing.T) {
+	defer func(token, def, fb string) {
+		config.AdminToken = token
+		config.SetProcessorURLs(map[string]string{"default": def, "fallback": fb})
+	}(config.AdminToken, config.ProcessorURL("default"), config.ProcessorURL("fallback"))
+	config.SetProcessorURLs(map[string]string{"default": "http://default:8080", "fallback": "http://fallback:8080"})
+	w := newTestWorker(nil)
+
+	tests := []struct {
+		name       string
+		adminToken string
+		method     string
+		token      string
+		body       string
+		wantCode   int
+	}{
+		{"wrong method", "secret", http.MethodGet, "secret", "", http.StatusMethodNotAllowed},
+		{"admin disabled", "", http.MethodPost, "", `{"fallback":"http://fb2:8080"}`, http.StatusForbidden},
+		{"wrong token", "secret", http.MethodPost, "guess", `{"fallback":"http://fb2:8080"}`, http.StatusUnauthorized},
+		{"empty body", "secret", http.MethodPost, "secret", `{}`, http.StatusBadRequest},
+		{"malformed body", "secret", http.MethodPost, "secret", `["http://fb2:8080"]`, http.StatusBadRequest},
+		{"unknown processor", "secret", http.MethodPost, "secret", `{"backup":"http://fb2:8080"}`, http.StatusBadRequest},
+		{"not http", "secret", http.MethodPost, "secret", `{"fallback":"ftp://fb2"}`, http.StatusBadRequest},
+		{"no host", "secret", http.MethodPost, "secret", `{"fallback":"http://"}`, http.StatusBadRequest},
+		{"one bad URL rejects all", "secret", http.MethodPost, "secret", `{"default":"http://d2:8080","fallback":"fb2"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.AdminToken = tt.adminToken
+			req := httptest.NewRequest(tt.method, "/config/processors", strings.NewReader(tt.body))
+			req.Header.Set("X-Admin-Token", tt.token)
+			rec := httptest.NewRecorder()
+			w.handleProcessorURLs(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if def, fb := config.ProcessorURL("default"), config.ProcessorURL("fallback"); def != "http://default:8080" || fb != "http://fallback:8080" {
+				t.Errorf("refused request changed the URLs to %s, %s", def, fb)
+			}
+		})
+	}
+
+	config.AdminToken = "secret"
+	req := httptest.NewRequest(http.MethodPost, "/config/processors", strings.NewReader(`{"fallback":"https://fb2:8443/"}`))
+	req.Header.Set("X-Admin-Token", "secret")
+	rec := httptest.NewRecorder()
+	w.handleProcessorURLs(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"default": "http://default:8080", "fallback": "https://fb2:8443"}
+	for name, u := range want {
+		if got[name] != u || config.ProcessorURL(name) != u {
+			t.Errorf("%s: responded %q, in use %q; want %q", name, got[name], config.ProcessorURL(name), u)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// outcomeBatcher coalesces the processed-payment writes of many payments
+// into one round trip (WORKER_BATCH_INSERTS), with the same batch size and
+// flush interval as the gateway's PaymentLogger. Until its batch commits a
+// payment stays in pending, which lookupProcessed consults so a duplicate
+// arriving in that window is still recognised.
+type outcomeBatcher struct {
+	ch       chan models.PaymentRequest
+	done     chan struct{}
+	write    func(context.Context, []models.PaymentRequest) (map[string]bool, error)
+	writeOne func(context.Context, models.PaymentRequest) error // fallback when batches keep failing
+	onCommit func(models.PaymentRequest)
+	onFlush  func(error) // the outcome of every batch write
+
+	mu      sync.Mutex
+	pending map[string]models.Amount
+	waiting atomic.Int64 // payments added and not yet flushed
+
+	// add holds sendMu for reading while it sends; close takes it for
+	// writing, so nothing is sent on the closed channel.
+	sendMu sync.RWMutex
+	closed bool
+}
+
+// newOutcomeBatcher writes batches to pool, falling back to writeOne per
+// payment when a batch fails batchWriteAttempts times in a row.
+func newOutcomeBatcher(pool *pgxpool.Pool, writeOne func(context.Context, models.PaymentRequest) error,
+	onCommit func(models.PaymentRequest), onFlush func(error)) *outcomeBatcher {
+	b := &outcomeBatcher{
+		ch:   make(chan models.PaymentRequest, 4096),
+		done: make(chan struct{}),
+		write: func(ctx context.Context, batch []models.PaymentRequest) (map[string]bool, error) {
+			return writeBatch(ctx, pool, batch)
+		},
+		writeOne: writeOne,
+		onCommit: onCommit,
+		onFlush:  onFlush,
+		pending:  make(map[string]models.Amount),
+	}
+	go b.loop()
+	return b
+}
+
+// add queues a processed payment, blocking when the buffer is full so that
+// a charged payment is never dropped. It reports false once the batcher is
+// closed; the caller must then write the payment itself.
+func (b *outcomeBatcher) add(req models.PaymentRequest) bool {
+	b.sendMu.RLock()
+	defer b.sendMu.RUnlock()
+	if b.closed {
+		return false
+	}
+	b.mu.Lock()
+	b.pending[req.CorrelationID] = req.Amount
+	b.mu.Unlock()
+	b.waiting.Add(1)
+	b.ch <- req
+	return true
+}
+
+// lookup reports whether the payment is waiting in a batch.
+func (b *outcomeBatcher) lookup(correlationID string) (models.Amount, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	amount, ok := b.pending[correlationID]
+	return amount, ok
+}
+
+// close flushes what is buffered and stops the batcher.
+func (b *outcomeBatcher) close() {
+	b.sendMu.Lock()
+	b.closed = true
+	close(b.ch)
+	b.sendMu.Unlock()
+	<-b.done
+}
+
+func (b *outcomeBatcher) loop() {
+	defer close(b.done)
+	ticker := time.NewTicker(config.LoggerFlushInterval)
+	defer ticker.Stop()
+	batch := make([]models.PaymentRequest, 0, config.LoggerBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		b.flush(batch)
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case req, ok := <-b.ch:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, req)
+			if len(batch) >= config.LoggerBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// batchWriteAttempts bounds how often a failing batch is written again
+// before its payments are written one by one.
+const batchWriteAttempts = 3
+
+// flush records batch. The payments in it are already charged, so a failed
+// write is retried and, as a last resort, each payment is written on its
+// own; only a payment that fails even then is lost, and it is logged.
+func (b *outcomeBatcher) flush(batch []models.PaymentRequest) {
+	// A repeated ID is one payment: write and count it once.
+	unique := make([]models.PaymentRequest, 0, len(batch))
+	seen := make(map[string]bool, len(batch))
+	for _, req := range batch {
+		if !seen[req.CorrelationID] {
+			seen[req.CorrelationID] = true
+			unique = append(unique, req)
+		}
+	}
+
+	var recorded map[string]bool
+	var err error
+	for attempt := 1; attempt <= batchWriteAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		recorded, err = b.write(ctx, unique)
+		cancel()
+		b.onFlush(err)
+		if err == nil {
+			break
+		}
+		logging.Errorf("Worker: Error inserting batch of %d payments (attempt %d): %v", len(unique), attempt, err)
+		if attempt < batchWriteAttempts {
+			time.Sleep(backoff.Delay(attempt, 50*time.Millisecond, time.Second))
+		}
+	}
+	if err != nil {
+		recorded = b.writeEach(unique)
+	}
+
+	b.mu.Lock()
+	for _, req := range batch {
+		delete(b.pending, req.CorrelationID)
+	}
+	b.mu.Unlock()
+	b.waiting.Add(-int64(len(batch)))
+	for _, req := range unique {
+		if recorded[req.CorrelationID] {
+			b.onCommit(req)
+		}
+	}
+}
+
+// writeEach writes the payments of a batch that kept failing one at a time,
+// so one bad row cannot lose the others.
+func (b *outcomeBatcher) writeEach(batch []models.PaymentRequest) map[string]bool {
+	recorded := make(map[string]bool, len(batch))
+	for _, req := range batch {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := b.writeOne(ctx, req)
+		cancel()
+		if err != nil {
+			logging.Errorf("Worker: payment %s was charged but could not be recorded: %v", req.CorrelationID, err)
+			continue
+		}
+		recorded[req.CorrelationID] = true
+	}
+	return recorded
+}
+
+// writeBatch writes batch like recordOutcome does row by row: rows already
+// logged by the gateway are upgraded to processed, missing ones are
+// inserted. It returns the IDs it recorded; one missing was already
+// processed, by an earlier pass or a concurrent direct write, and must not
+// be counted again. ON CONFLICT has no target since the partitioned table
+// has no unique index on correlation_id alone (payment_ids covers it there).
+func writeBatch(ctx context.Context, pool *pgxpool.Pool, batch []models.PaymentRequest) (map[string]bool, error) {
+	var values strings.Builder
+	args := make([]any, 0, len(batch)*4)
+	for i, req := range batch {
+		if i > 0 {
+			values.WriteString(",")
+		}
+		n := len(args)
+		fmt.Fprintf(&values, "($%d::text,$%d::numeric,$%d::text,$%d::int)", n+1, n+2, n+3, n+4)
+		args = append(args, req.CorrelationID, req.Amount, req.Processor, req.Attempts+1)
+	}
+	pgBatch := &pgx.Batch{}
+	pgBatch.Queue(`UPDATE payments p SET amount = v.amount, processor = v.processor, status = 'processed', attempts = v.attempts, last_error = NULL
+        FROM (VALUES `+values.String()+`) AS v(correlation_id, amount, processor, attempts)
+        WHERE p.correlation_id = v.correlation_id AND p.status <> 'processed'
+        RETURNING p.correlation_id`, args...)
+	pgBatch.Queue(`INSERT INTO payments (correlation_id, amount, processor, status, attempts)
+        SELECT v.correlation_id, v.amount, v.processor, 'processed', v.attempts
+        FROM (VALUES `+values.String()+`) AS v(correlation_id, amount, processor, attempts)
+        WHERE NOT EXISTS (SELECT 1 FROM payments p WHERE p.correlation_id = v.correlation_id)
+        ON CONFLICT DO NOTHING
+        RETURNING correlation_id`, args...)
+
+	// Both statements run in the implicit transaction of the batch.
+	results := pool.SendBatch(ctx, pgBatch)
+	recorded := make(map[string]bool, len(batch))
+	for i := 0; i < 2; i++ {
+		rows, err := results.Query()
+		if err != nil {
+			results.Close()
+			return nil, err
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			results.Close()
+			return nil, err
+		}
+		for _, id := range ids {
+			recorded[id] = true
+		}
+	}
+	return recorded, results.Close()
+}
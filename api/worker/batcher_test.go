@@ -0,0 +1,213 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+// fakeOutcomes stands in for the payments table of an outcomeBatcher.
+type fakeOutcomes struct {
+	mu        sync.Mutex
+	rows      map[string]string // correlation ID to status
+	failBatch bool
+	failOne   map[string]bool
+	flushErrs int
+	committed map[string]int
+	processor map[string]string // processor of the last committed outcome
+}
+
+func newFakeOutcomes() *fakeOutcomes {
+	return &fakeOutcomes{rows: map[string]string{}, failOne: map[string]bool{}, committed: map[string]int{}, processor: map[string]string{}}
+}
+
+func (f *fakeOutcomes) batcher() *outcomeBatcher {
+	b := &outcomeBatcher{
+		ch:      make(chan models.PaymentRequest, 16),
+		done:    make(chan struct{}),
+		pending: make(map[string]models.Amount),
+		write: func(_ context.Context, batch []models.PaymentRequest) (map[string]bool, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if f.failBatch {
+				return nil, errors.New("batch failed")
+			}
+			recorded := map[string]bool{}
+			for _, req := range batch {
+				if recorded[req.CorrelationID] {
+					panic("repeated ID in batch: " + req.CorrelationID)
+				}
+				if f.rows[req.CorrelationID] != models.StatusProcessed {
+					f.rows[req.CorrelationID] = models.StatusProcessed
+					recorded[req.CorrelationID] = true
+				}
+			}
+			return recorded, nil
+		},
+		writeOne: func(_ context.Context, req models.PaymentRequest) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if f.failOne[req.CorrelationID] {
+				return errors.New("row failed")
+			}
+			f.rows[req.CorrelationID] = models.StatusProcessed
+			return nil
+		},
+		onCommit: func(req models.PaymentRequest) {
+			f.mu.Lock()
+			f.committed[req.CorrelationID]++
+			f.processor[req.CorrelationID] = req.Processor
+			f.mu.Unlock()
+		},
+		onFlush: func(err error) {
+			if err != nil {
+				f.mu.Lock()
+				f.flushErrs++
+				f.mu.Unlock()
+			}
+		},
+	}
+	go b.loop()
+	return b
+}
+
+func setBatcherConfig(t *testing.T) {
+	t.Helper()
+	size, interval := config.LoggerBatchSize, config.LoggerFlushInterval
+	config.LoggerBatchSize, config.LoggerFlushInterval = 100, time.Hour
+	t.Cleanup(func() { config.LoggerBatchSize, config.LoggerFlushInterval = size, interval })
+}
+
+func payment(id string) models.PaymentRequest {
+	return models.PaymentRequest{CorrelationID: id, Amount: "10.00", Processor: "default"}
+}
+
+func TestOutcomeBatcher(t *testing.T) {
+	tests := []struct {
+		name          string
+		existing      map[string]string
+		failBatch     bool
+		failOne       []string
+		add           []string
+		wantCommitted map[string]int
+		wantFlushErrs int
+	}{
+		{
+			name:          "each payment counted once",
+			add:           []string{"a", "b", "a"},
+			wantCommitted: map[string]int{"a": 1, "b": 1},
+		},
+		{
+			name:          "already processed not counted",
+			existing:      map[string]string{"a": models.StatusProcessed, "b": models.StatusReceived},
+			add:           []string{"a", "b"},
+			wantCommitted: map[string]int{"b": 1},
+		},
+		{
+			name:          "failing batch written row by row",
+			failBatch:     true,
+			failOne:       []string{"b"},
+			add:           []string{"a", "b", "c"},
+			wantCommitted: map[string]int{"a": 1, "c": 1},
+			wantFlushErrs: batchWriteAttempts,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setBatcherConfig(t)
+			f := newFakeOutcomes()
+			for id, status := range tt.existing {
+				f.rows[id] = status
+			}
+			f.failBatch = tt.failBatch
+			for _, id := range tt.failOne {
+				f.failOne[id] = true
+			}
+			b := f.batcher()
+			for _, id := range tt.add {
+				if !b.add(payment(id)) {
+					t.Fatalf("add(%s) refused by an open batcher", id)
+				}
+				if _, ok := b.lookup(id); !ok {
+					t.Errorf("lookup(%s) = false while the payment is pending", id)
+				}
+			}
+			b.close()
+
+			if fmt.Sprint(f.committed) != fmt.Sprint(tt.wantCommitted) {
+				t.Errorf("committed = %v, want %v", f.committed, tt.wantCommitted)
+			}
+			if f.flushErrs != tt.wantFlushErrs {
+				t.Errorf("failed batch writes = %d, want %d", f.flushErrs, tt.wantFlushErrs)
+			}
+			if n := b.waiting.Load(); n != 0 {
+				t.Errorf("waiting = %d after close, want 0", n)
+			}
+			for _, id := range tt.add {
+				if _, ok := b.lookup(id); ok {
+					t.Errorf("lookup(%s) = true after the batch was flushed", id)
+				}
+			}
+			if b.add(payment("late")) {
+				t.Error("add after close = true, want false")
+			}
+		})
+	}
+}
+
+func TestWriteBatchDB(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, `INSERT INTO payments (correlation_id, amount, status) VALUES ('received', 1, 'received'), ('processed', 1, 'processed')`); err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := writeBatch(ctx, pool, []models.PaymentRequest{payment("received"), payment("processed"), payment("new")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"received": true, "new": true}
+	if fmt.Sprint(recorded) != fmt.Sprint(want) {
+		t.Errorf("recorded = %v, want %v", recorded, want)
+	}
+	var processed int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM payments WHERE status = 'processed'").Scan(&processed); err != nil {
+		t.Fatal(err)
+	}
+	if processed != 3 {
+		t.Errorf("processed rows = %d, want 3", processed)
+	}
+}
+
+// BenchmarkOutcomeWrites compares recording processed payments batched
+// (WORKER_BATCH_INSERTS) with one write per payment.
+func BenchmarkOutcomeWrites(b *testing.B) {
+	pool := testPool(b)
+	ctx := context.Background()
+	const batchSize = 100
+	b.Run("per-row", func(b *testing.B) {
+		w := &Worker{db: pool}
+		for i := 0; i < b.N; i++ {
+			if err := w.recordOutcome(ctx, payment(fmt.Sprintf("row-%d", i)), models.StatusProcessed, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		batch := make([]models.PaymentRequest, 0, batchSize)
+		for i := 0; i < b.N; i++ {
+			batch = append(batch, payment(fmt.Sprintf("batch-%d", i)))
+			if len(batch) == batchSize || i == b.N-1 {
+				if _, err := writeBatch(ctx, pool, batch); err != nil {
+					b.Fatal(err)
+				}
+				batch = batch[:0]
+			}
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// bloomFilter is a fixed-size, lock-free bloom filter over correlation IDs.
+// A negative answer is definitive for the IDs added to it, i.e. the payments
+// this worker recorded, which is why config only enables it for a sole
+// writer; a positive one only means "maybe" and must be confirmed against
+// the payments table.
+type bloomFilter struct {
+	words  []atomic.Uint64
+	bits   uint64
+	hashes int
+}
+
+func newBloomFilter(bits, hashes int) *bloomFilter {
+	if bits < 64 {
+		bits = 64
+	}
+	if hashes < 1 {
+		hashes = 1
+	}
+	n := (bits + 63) / 64
+	return &bloomFilter{words: make([]atomic.Uint64, n), bits: uint64(n * 64), hashes: hashes}
+}
+
+// positions derives the filter's bit positions for key by double hashing.
+func (b *bloomFilter) positions(key string, fn func(pos uint64) bool) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31
+	h2 |= 1
+	for i := 0; i < b.hashes; i++ {
+		if !fn((h1 + uint64(i)*h2) % b.bits) {
+			return
+		}
+	}
+}
+
+func (b *bloomFilter) add(key string) {
+	b.positions(key, func(pos uint64) bool {
+		word, mask := &b.words[pos/64], uint64(1)<<(pos%64)
+		for {
+			old := word.Load()
+			if old&mask != 0 || word.CompareAndSwap(old, old|mask) {
+				return true
+			}
+		}
+	})
+}
+
+func (b *bloomFilter) mayContain(key string) bool {
+	found := true
+	b.positions(key, func(pos uint64) bool {
+		if b.words[pos/64].Load()&(uint64(1)<<(pos%64)) == 0 {
+			found = false
+		}
+		return found
+	})
+	return found
+}
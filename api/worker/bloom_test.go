@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	b := newBloomFilter(1<<16, 4)
+	for i := 0; i < 1000; i++ {
+		b.add(fmt.Sprintf("added-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if id := fmt.Sprintf("added-%d", i); !b.mayContain(id) {
+			t.Fatalf("mayContain(%s) = false for an added ID", id)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		if b.mayContain(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 10 {
+		t.Errorf("%d/1000 false positives, want at most 10", falsePositives)
+	}
+}
+
+func TestLookupProcessedBloom(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, `INSERT INTO payments (correlation_id, amount) VALUES ('in-db', 5), ('also-in-db', 5)`); err != nil {
+		t.Fatal(err)
+	}
+	w := &Worker{db: pool, seen: newBloomFilter(1<<16, 4)}
+	w.seen.add("in-db")
+	w.seen.add("new-but-maybe") // a false positive as far as the table goes
+
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"in-db", true},          // maybe, confirmed by the table
+		{"new-but-maybe", false}, // maybe, refuted by the table
+		{"never-seen", false},    // definite no, table not asked
+		{"also-in-db", false},    // recorded by another writer: why the filter needs a sole writer
+	}
+	for _, tt := range tests {
+		exists, _, err := w.lookupProcessed(ctx, tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != tt.want {
+			t.Errorf("lookupProcessed(%s) = %t, want %t", tt.id, exists, tt.want)
+		}
+	}
+}
+
+func TestLookupProcessedBloomSkipsTable(t *testing.T) {
+	w := &Worker{seen: newBloomFilter(1<<16, 4)} // no database: a table lookup would panic
+	exists, _, err := w.lookupProcessed(context.Background(), "never-seen")
+	if exists || err != nil {
+		t.Errorf("lookupProcessed = %t, %v, want false, nil", exists, err)
+	}
+}
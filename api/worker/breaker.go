@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// circuitBreaker takes a processor out of routing after
+// config.BreakerFailures consecutive live calls timed out or found it
+// unavailable, which reacts faster than the health poll. Once
+// config.BreakerCooldown has passed, calls go through again (half-open): a
+// success closes the breaker, another failure trips it for a new cooldown.
+type circuitBreaker struct {
+	name      string
+	mu        sync.Mutex
+	failures  int       // consecutive failed calls
+	trippedAt time.Time // zero while closed
+}
+
+// open reports whether the breaker currently keeps calls away.
+func (b *circuitBreaker) open(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.trippedAt.IsZero() && now.Sub(b.trippedAt) < config.BreakerCooldown
+}
+
+// record accounts for one processor call. Only failures that say the
+// processor is down count; a rejection or a bad response came from a
+// processor that is up.
+func (b *circuitBreaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil || !retryable(err) {
+		if !b.trippedAt.IsZero() {
+			logging.Infof("Worker: circuit breaker for %s closed", b.name)
+		}
+		b.failures = 0
+		b.trippedAt = time.Time{}
+		return
+	}
+	b.failures++
+	// Calls already in flight when the breaker tripped do not extend the
+	// cooldown; a failure once it has passed starts a new one.
+	if b.failures >= config.BreakerFailures && (b.trippedAt.IsZero() || now.Sub(b.trippedAt) >= config.BreakerCooldown) {
+		b.trippedAt = now
+		logging.Warnf("Worker: circuit breaker for %s tripped after %d consecutive failures", b.name, b.failures)
+	}
+}
+
+func (b *circuitBreaker) status(now time.Time) models.BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := models.BreakerStatus{State: "closed", Failures: b.failures}
+	if !b.trippedAt.IsZero() {
+		since := b.trippedAt
+		s.TrippedSince = &since
+		s.State = "open"
+		if now.Sub(b.trippedAt) >= config.BreakerCooldown {
+			s.State = "half-open"
+		}
+	}
+	return s
+}
+
+// breakerAllows reports whether name's breaker lets calls through; always
+// true when breakers are disabled.
+func (w *Worker) breakerAllows(name string, now time.Time) bool {
+	b := w.breakers[name]
+	return b == nil || !b.open(now)
+}
+
+// handleStatus reports each processor's polled health next to its breaker
+// state, the two views of whether it can take payments.
+func (w *Worker) handleStatus(wr http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	defHealthy, fbHealthy := w.currentHealth()
+	resp := map[string]models.ProcessorStatus{
+		"default":  {Healthy: defHealthy, Degraded: w.defaultDegraded.Load(), MinResponseTime: w.defaultLatency.Load()},
+		"fallback": {Healthy: fbHealthy, Degraded: w.fallbackDegraded.Load(), MinResponseTime: w.fallbackLatency.Load()},
+	}
+	for name, s := range resp {
+		if b := w.breakers[name]; b != nil {
+			bs := b.status(now)
+			s.Breaker = &bs
+		}
+		s.Available = s.Healthy && w.breakerAllows(name, now)
+		resp[name] = s
+	}
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(resp)
+}
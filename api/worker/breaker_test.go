@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+// TestCircuitBreaker drives the breaker with explicit times, so tripping,
+// half-opening and closing depend only on the now passed in.
+func TestCircuitBreaker(t *testing.T) {
+	defer func(failures int, cooldown time.Duration) {
+		config.BreakerFailures, config.BreakerCooldown = failures, cooldown
+	}(config.BreakerFailures, config.BreakerCooldown)
+	config.BreakerFailures = 3
+	config.BreakerCooldown = time.Second
+
+	type call struct {
+		at  time.Duration // since the first call
+		err error
+	}
+	down := ErrProcessorUnavailable
+	tests := []struct {
+		name      string
+		calls     []call
+		checkAt   time.Duration
+		wantState string
+		wantOpen  bool
+	}{
+		{"below the threshold", []call{{0, down}, {10 * time.Millisecond, down}}, 20 * time.Millisecond, "closed", false},
+		{"trips at the threshold", []call{{0, down}, {0, ErrProcessorTimeout}, {0, down}}, 10 * time.Millisecond, "open", true},
+		{"success resets the count", []call{{0, down}, {0, down}, {0, nil}, {0, down}}, 10 * time.Millisecond, "closed", false},
+		{"rejection does not count", []call{{0, down}, {0, down}, {0, ErrProcessorRejected}, {0, down}}, 10 * time.Millisecond, "closed", false},
+		{"half-open after the cooldown", []call{{0, down}, {0, down}, {0, down}}, time.Second, "half-open", false},
+		{"in-flight failure keeps the cooldown", []call{{0, down}, {0, down}, {0, down}, {900 * time.Millisecond, down}}, time.Second, "half-open", false},
+		{"half-open failure trips again", []call{{0, down}, {0, down}, {0, down}, {time.Second, down}}, 1500 * time.Millisecond, "open", true},
+		{"half-open success closes", []call{{0, down}, {0, down}, {0, down}, {time.Second, nil}}, 1500 * time.Millisecond, "closed", false},
+	}
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &circuitBreaker{name: "default"}
+			for _, c := range tt.calls {
+				b.record(c.err, start.Add(c.at))
+			}
+			now := start.Add(tt.checkAt)
+			if got := b.open(now); got != tt.wantOpen {
+				t.Errorf("open = %t, want %t", got, tt.wantOpen)
+			}
+			if got := b.status(now).State; got != tt.wantState {
+				t.Errorf("state = %q, want %q", got, tt.wantState)
+			}
+		})
+	}
+}
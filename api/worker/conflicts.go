@@ -0,0 +1,19 @@
+package worker
+
+import (
+	"context"
+
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// recordConflict logs and audits a payment whose correlation ID was already
+// processed with a different amount. The payment is not processed again.
+func (w *Worker) recordConflict(ctx context.Context, req models.PaymentRequest, existingAmount models.Amount) {
+	logging.Warnf("Worker: Conflict for correlation ID %s: already processed with amount %s, got %s",
+		req.CorrelationID, existingAmount, req.Amount)
+	if _, err := w.db.Exec(ctx, "INSERT INTO payment_conflicts (correlation_id, existing_amount, conflicting_amount) VALUES ($1,$2,$3)",
+		req.CorrelationID, existingAmount, req.Amount); err != nil {
+		logging.Errorf("Worker: Error recording conflict for %s: %v", req.CorrelationID, err)
+	}
+}
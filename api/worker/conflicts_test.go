@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"rinha-backend-golang/models"
+)
+
+func TestProcessPaymentRecordsConflict(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, "TRUNCATE payment_conflicts"); err != nil {
+		t.Fatal(err)
+	}
+	const id = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+	if _, err := pool.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor) VALUES ($1, 10, 'default')", id); err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWorker(nil)
+	w.db = pool
+	w.dbHealthy.Store(true)
+
+	// The same amount written differently is a plain duplicate; only a
+	// different one is audited.
+	w.processPayment(models.PaymentRequest{CorrelationID: id, Amount: "10.0"})
+	w.processPayment(models.PaymentRequest{CorrelationID: id, Amount: "20.00"})
+
+	var existing, conflicting models.Amount
+	var n int
+	err := pool.QueryRow(ctx, "SELECT count(*), min(existing_amount)::text, min(conflicting_amount)::text FROM payment_conflicts WHERE correlation_id=$1",
+		id).Scan(&n, &existing, &conflicting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || !existing.Equal("10") || !conflicting.Equal("20") {
+		t.Errorf("recorded %d conflicts with amounts %s and %s, want 1 with 10 and 20", n, existing, conflicting)
+	}
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/config"
+)
+
+// testPool connects to the database named by TEST_POSTGRES_DSN with empty
+// payment tables, skipping the test when it is not set.
+func testPool(tb testing.TB) *pgxpool.Pool {
+	tb.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		tb.Skip("TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(pool.Close)
+	config.RunMigrations = true
+	if err := config.EnsureSchema(ctx, pool); err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := pool.Exec(ctx, "TRUNCATE payments, payments_pruned_totals, payment_dead_letters, payment_conflicts"); err != nil {
+		tb.Fatal(err)
+	}
+	return pool
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// startDBPinger tracks Postgres reachability in w.dbHealthy. pgxpool already
+// re-dials broken connections on demand; the pinger only decides whether it is
+// worth trying. While the database is down it re-pings with exponential
+// backoff, capped at the normal interval. While it is up but payment inserts
+// are failing, it also probes whether writes work again.
+func (w *Worker) startDBPinger() {
+	const minBackoff = 250 * time.Millisecond
+	failures := 0
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		err := w.db.Ping(ctx)
+		cancel()
+
+		wasHealthy := w.dbHealthy.Swap(err == nil)
+		switch {
+		case err == nil:
+			if !wasHealthy {
+				logging.Infof("Worker: Postgres reachable again")
+			}
+			failures = 0
+			if w.insertsFailing() {
+				w.probeInserts()
+			}
+			time.Sleep(config.DBPingInterval)
+		default:
+			if wasHealthy {
+				logging.Errorf("Worker: Postgres unreachable: %v", err)
+			}
+			failures++
+			time.Sleep(backoff.Delay(failures, minBackoff, config.DBPingInterval))
+		}
+	}
+}
+
+// handleReadyz reports 503 while the database is unreachable or payment
+// inserts keep failing, so load balancers stop sending work that could not be
+// recorded, and until the first processor health poll has completed.
+func (w *Worker) handleReadyz(wr http.ResponseWriter, r *http.Request) {
+	if !w.dbHealthy.Load() {
+		http.Error(wr, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if w.insertsFailing() {
+		http.Error(wr, "payment inserts failing", http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case <-w.healthPolled:
+	default:
+		http.Error(wr, "processor health not known yet", http.StatusServiceUnavailable)
+		return
+	}
+	wr.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/backoff"
+)
+
+// TestPaymentsSurviveDBOutage takes the database down, checks that new
+// payments are refused and an accepted one is kept, then brings it back and
+// checks the kept payment is charged and recorded once.
+func TestPaymentsSurviveDBOutage(t *testing.T) {
+	setBatcherConfig(t)
+	backoff.SetJitter(backoff.JitterNone)
+	defer backoff.SetJitter(backoff.JitterFull)
+
+	fake := newFakeProcessors(nil)
+	outcomes := newFakeOutcomes()
+	w := newTestWorker(fake)
+	w.seen = newBloomFilter(1024, 3)
+	w.batcher = outcomes.batcher()
+	w.defaultHealthy.Store(true)
+	// Scheduled passes hold a slot while they run, which lets the test
+	// wait for them below.
+	w.inflight = make(chan struct{}, 1)
+	w.dbHealthy.Store(false)
+
+	if w.Submit(payment("p0")) {
+		t.Error("Submit accepted a payment while Postgres is down")
+	}
+	rec := httptest.NewRecorder()
+	w.handleProcessPayment(rec, httptest.NewRequest(http.MethodPost, "/process-payment",
+		strings.NewReader(`{"correlationId":"p0","amount":10}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/process-payment = %d while Postgres is down, want 503", rec.Code)
+	}
+
+	// A payment already accepted when the outage began waits for it to end.
+	w.processPayment(payment("p1"))
+	if n := fake.callCount("default"); n != 0 {
+		t.Fatalf("processor calls = %d during the outage, want 0", n)
+	}
+	if n := w.scheduled.len(); n != 1 {
+		t.Fatalf("scheduled passes = %d, want 1", n)
+	}
+	if got := w.scheduled.list()[0].Attempts; got != 0 {
+		t.Errorf("postponed pass has Attempts = %d, want 0", got)
+	}
+
+	w.dbHealthy.Store(true)
+	deadline := time.Now().Add(2 * time.Second)
+	for w.scheduled.len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("postponed pass did not start after the database came back")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case w.inflight <- struct{}{}:
+	case <-time.After(2 * time.Second):
+		t.Fatal("postponed pass did not finish")
+	}
+	w.batcher.close()
+
+	if got := outcomes.processor["p1"]; got != "default" {
+		t.Errorf("p1 recorded with %q, want default", got)
+	}
+	if n := fake.callCount("default"); n != 1 {
+		t.Errorf("processor calls = %d, want 1", n)
+	}
+}
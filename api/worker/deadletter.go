@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// reprocessDelay is the exponential backoff before processing pass attempt+1.
+func reprocessDelay(attempt int) time.Duration {
+	return backoff.Delay(attempt, config.ReprocessBaseDelay, config.ReprocessMaxDelay)
+}
+
+// retryOrDeadLetter is called after a processing pass failed on every
+// processor. It schedules another pass with backoff, or dead-letters the
+// payment once config.MaxProcessAttempts passes were made or a processor
+// rejected it outright. A processor already holding the payment, with no
+// timed-out attempt of ours to explain it, means another pass or worker
+// charged it; that one records it, so this pass records nothing.
+func (w *Worker) retryOrDeadLetter(req models.PaymentRequest, lastErr error) {
+	if errors.Is(lastErr, ErrProcessorDuplicate) {
+		logging.Warnf("Worker: processor already holds payment %s, leaving it to the pass that charged it", req.CorrelationID)
+		w.unconfirmed.remove(req.CorrelationID)
+		return
+	}
+	req.Attempts++
+	if req.Attempts >= config.MaxProcessAttempts || errors.Is(lastErr, ErrProcessorRejected) {
+		w.recordFailure(req, models.StatusFailed, lastErr)
+		w.deadLetter(req)
+		return
+	}
+	w.recordFailure(req, models.StatusRetrying, lastErr)
+	delay := reprocessDelay(req.Attempts)
+	logging.Paymentf(req.CorrelationID, "Worker: Re-queueing payment %s for attempt %d in %s", req.CorrelationID, req.Attempts+1, delay)
+	w.scheduled.add(req)
+	time.AfterFunc(delay, func() { w.reprocess(req) })
+}
+
+// reprocess runs a scheduled pass within the in-flight limit, waiting another
+// backoff period if the worker is at capacity.
+func (w *Worker) reprocess(req models.PaymentRequest) {
+	if !w.acquireSlot() {
+		time.AfterFunc(reprocessDelay(req.Attempts), func() { w.reprocess(req) })
+		return
+	}
+	defer w.releaseSlot()
+	w.scheduled.remove(req.CorrelationID)
+	w.processPayment(req)
+}
+
+func (w *Worker) recordFailure(req models.PaymentRequest, status string, lastErr error) {
+	ctx, cancel := dbContext(context.Background())
+	defer cancel()
+	if err := w.recordOutcome(ctx, req, status, lastErr); err != nil {
+		logging.Errorf("Worker: Error recording %s status for payment %s: %v", status, req.CorrelationID, err)
+	}
+}
+
+func (w *Worker) deadLetter(req models.PaymentRequest) {
+	w.unconfirmed.remove(req.CorrelationID)
+	logging.Errorf("Worker: Dead-lettering payment %s after %d attempts", req.CorrelationID, req.Attempts)
+	ctx, cancel := dbContext(context.Background())
+	defer cancel()
+	if _, err := w.db.Exec(ctx, `INSERT INTO payment_dead_letters (correlation_id, amount, attempts)
+        VALUES ($1,$2,$3) ON CONFLICT (correlation_id) DO UPDATE SET attempts = EXCLUDED.attempts, failed_at = now()`,
+		req.CorrelationID, req.Amount, req.Attempts); err != nil {
+		logging.Errorf("Worker: Error dead-lettering payment %s: %v", req.CorrelationID, err)
+	}
+}
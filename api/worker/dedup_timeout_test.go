@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+)
+
+// stallingPool returns a pool whose server accepts connections and never
+// answers, like a hung database.
+func stallingPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	return fakeServerPool(t, false)
+}
+
+// resettingPool returns a pool whose server closes every connection as soon
+// as it is accepted, so queries fail at once rather than time out.
+func resettingPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	return fakeServerPool(t, true)
+}
+
+func fakeServerPool(t *testing.T, reset bool) *pgxpool.Pool {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns []net.Conn
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if reset {
+				conn.Close()
+				continue
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		<-done
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	pool, err := pgxpool.New(context.Background(), "postgres://u:p@"+ln.Addr().String()+"/db?sslmode=disable&connect_timeout=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestDuplicateCheckStallingDB(t *testing.T) {
+	defer func(timeout time.Duration, attempts int) {
+		config.DBQueryTimeout, config.MaxProcessAttempts = timeout, attempts
+	}(config.DBQueryTimeout, config.MaxProcessAttempts)
+	config.DBQueryTimeout = 50 * time.Millisecond
+	// No jitter, so the scheduled pass cannot start before it is checked.
+	backoff.SetJitter(backoff.JitterNone)
+	defer backoff.SetJitter(backoff.JitterFull)
+
+	tests := []struct {
+		name          string
+		pool          func(*testing.T) *pgxpool.Pool
+		attempts      int // payment's passes so far
+		maxAttempts   int
+		wantScheduled int
+	}{
+		{"timeout re-queued as another pass", stallingPool, 0, 2, 1},
+		{"timeout dead-lettered after the last pass", stallingPool, 1, 2, 0},
+		{"connection error re-queued as another pass", resettingPool, 0, 2, 1},
+		{"connection error dead-lettered after the last pass", resettingPool, 1, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.MaxProcessAttempts = tt.maxAttempts
+			fake := newFakeProcessors(nil)
+			w := newTestWorker(fake)
+			w.db = tt.pool(t)
+			w.dbHealthy.Store(true)
+			// Scheduled passes hold a slot while they run, which lets the
+			// test wait for them below.
+			w.inflight = make(chan struct{}, 1)
+			req := payment("p1")
+			req.Attempts = tt.attempts
+
+			start := time.Now()
+			w.processPayment(req)
+			// The lookup, the status write and the dead-letter insert are
+			// each bounded by DBQueryTimeout.
+			if elapsed := time.Since(start); elapsed > 10*config.DBQueryTimeout {
+				t.Errorf("processPayment took %s with a failing database", elapsed)
+			}
+			if n := fake.callCount("default") + fake.callCount("fallback"); n != 0 {
+				t.Errorf("processor calls = %d, want 0", n)
+			}
+			if n := w.scheduled.len(); n != tt.wantScheduled {
+				t.Fatalf("scheduled passes = %d, want %d", n, tt.wantScheduled)
+			}
+			if tt.wantScheduled > 0 {
+				if got := w.scheduled.list()[0].Attempts; got != tt.attempts+1 {
+					t.Errorf("scheduled pass has Attempts = %d, want %d", got, tt.attempts+1)
+				}
+			}
+
+			// The scheduled pass fails again and dead-letters the payment.
+			// It leaves the scheduled set once it holds the slot, and frees
+			// the slot when it is done.
+			deadline := time.Now().Add(2 * time.Second)
+			for w.scheduled.len() > 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("scheduled pass did not start")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			select {
+			case w.inflight <- struct{}{}:
+			case <-time.After(2 * time.Second):
+				t.Fatal("scheduled pass did not finish")
+			}
+		})
+	}
+}
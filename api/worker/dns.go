@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// startDNSRefresh re-resolves the processor hosts every config.ProcessorDNSTTL.
+// Go resolves on every dial, but pooled connections keep pointing at the
+// address they were dialed to; when a processor container restarts with a
+// new IP those stale connections only fail one payment at a time. Dropping
+// the idle pool as soon as an address changes makes the next call redial.
+// The hosts are taken from the current processor URLs on every tick, so a
+// processor repointed through /config/processors is followed.
+func (w *Worker) startDNSRefresh() {
+	transport, ok := w.httpClient.Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(config.ProcessorDNSTTL)
+	defer ticker.Stop()
+	hosts := map[string][]string{}
+	for {
+		current := map[string][]string{}
+		for _, u := range []string{config.ProcessorURL("default"), config.ProcessorURL("fallback")} {
+			if parsed, err := neturl.Parse(u); err == nil && parsed.Hostname() != "" {
+				current[parsed.Hostname()] = hosts[parsed.Hostname()]
+			}
+		}
+		hosts = current
+		changed := false
+		for host, prev := range hosts {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+			cancel()
+			if err != nil {
+				logging.Warnf("Worker: could not re-resolve %s: %v", host, err)
+				continue
+			}
+			slices.Sort(addrs)
+			if prev != nil && !slices.Equal(prev, addrs) {
+				logging.Infof("Worker: %s moved from %v to %v, dropping idle connections", host, prev, addrs)
+				changed = true
+			}
+			hosts[host] = addrs
+		}
+		if changed {
+			transport.CloseIdleConnections()
+		}
+		<-ticker.C
+	}
+}
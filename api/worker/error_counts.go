@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"rinha-backend-golang/models"
+)
+
+// errorCounts counts one processor's failed calls by ErrProcessor class.
+type errorCounts struct {
+	timeout, rateLimited, unavailable, duplicate, rejected, badResponse, other atomic.Int64
+}
+
+func (c *errorCounts) add(err error) {
+	switch {
+	case errors.Is(err, ErrProcessorTimeout):
+		c.timeout.Add(1)
+	case errors.Is(err, ErrProcessorRateLimited):
+		c.rateLimited.Add(1)
+	case errors.Is(err, ErrProcessorUnavailable):
+		c.unavailable.Add(1)
+	case errors.Is(err, ErrProcessorDuplicate):
+		c.duplicate.Add(1)
+	case errors.Is(err, ErrProcessorRejected):
+		c.rejected.Add(1)
+	case errors.Is(err, ErrProcessorBadResponse):
+		c.badResponse.Add(1)
+	default:
+		c.other.Add(1)
+	}
+}
+
+func (c *errorCounts) snapshot() models.ProcessorErrorCounts {
+	return models.ProcessorErrorCounts{
+		Timeout:     c.timeout.Load(),
+		RateLimited: c.rateLimited.Load(),
+		Unavailable: c.unavailable.Load(),
+		Duplicate:   c.duplicate.Load(),
+		Rejected:    c.rejected.Load(),
+		BadResponse: c.badResponse.Load(),
+		Other:       c.other.Load(),
+	}
+}
+
+// processorErrors counts failed processor calls per processor and class,
+// showing why payments fail and what keeps a processor marked unhealthy.
+type processorErrors struct {
+	def, fallback errorCounts
+}
+
+func (e *processorErrors) add(name string, err error) {
+	if name == "default" {
+		e.def.add(err)
+	} else {
+		e.fallback.add(err)
+	}
+}
+
+// handleProcessorErrors reports this worker's failed processor calls by class.
+func (w *Worker) handleProcessorErrors(wr http.ResponseWriter, r *http.Request) {
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(map[string]models.ProcessorErrorCounts{
+		"default":  w.callErrors.def.snapshot(),
+		"fallback": w.callErrors.fallback.snapshot(),
+	})
+}
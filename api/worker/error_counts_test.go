@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rinha-backend-golang/models"
+)
+
+// TestCallProcessorErrorCounts stubs one failure of each class and checks
+// callProcessor counts it under the right processor and class.
+func TestCallProcessorErrorCounts(t *testing.T) {
+	fake := newFakeProcessors(map[string][]error{
+		"default": {
+			fmt.Errorf("%w: deadline exceeded", ErrProcessorTimeout),
+			fmt.Errorf("%w: status 429", ErrProcessorRateLimited),
+			fmt.Errorf("%w: status 503", ErrProcessorUnavailable),
+			fmt.Errorf("%w: status 500", ErrProcessorUnavailable),
+			fmt.Errorf("%w: status 400", ErrProcessorRejected),
+			fmt.Errorf("%w: missing message", ErrProcessorBadResponse),
+			errors.New("something else"),
+		},
+		"fallback": {fmt.Errorf("%w: status 422", ErrProcessorDuplicate)},
+	})
+	w := newTestWorker(fake)
+	for i := 0; i < 7; i++ {
+		w.callProcessor(context.Background(), "default", "http://default", payment("p1"))
+	}
+	w.callProcessor(context.Background(), "fallback", "http://fallback", payment("p1"))
+
+	// Our own rate limit counts as rate limited without calling out.
+	w.limiter = newRateLimiter(1, 1, 0)
+	for i := 0; i < 2; i++ {
+		w.callProcessor(context.Background(), "fallback", "http://fallback", payment("p2"))
+	}
+
+	want := map[string]models.ProcessorErrorCounts{
+		"default":  {Timeout: 1, RateLimited: 1, Unavailable: 2, Rejected: 1, BadResponse: 1, Other: 1},
+		"fallback": {Duplicate: 1, RateLimited: 1},
+	}
+	got := map[string]models.ProcessorErrorCounts{
+		"default":  w.callErrors.def.snapshot(),
+		"fallback": w.callErrors.fallback.snapshot(),
+	}
+	for name := range want {
+		if got[name] != want[name] {
+			t.Errorf("%s: counts %+v, want %+v", name, got[name], want[name])
+		}
+	}
+	if n := fake.callCount("fallback"); n != 2 {
+		t.Errorf("fallback called %d times, want 2", n)
+	}
+}
+
+func TestHandleProcessorErrors(t *testing.T) {
+	w := newTestWorker(nil)
+	w.callErrors.add("default", fmt.Errorf("%w: slow", ErrProcessorTimeout))
+	w.callErrors.add("fallback", fmt.Errorf("%w: status 502", ErrProcessorUnavailable))
+
+	rec := httptest.NewRecorder()
+	w.handleProcessorErrors(rec, httptest.NewRequest(http.MethodGet, "/processor-errors", nil))
+	var got map[string]models.ProcessorErrorCounts
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["default"] != (models.ProcessorErrorCounts{Timeout: 1}) || got["fallback"] != (models.ProcessorErrorCounts{Unavailable: 1}) {
+		t.Errorf("counts %+v", got)
+	}
+}
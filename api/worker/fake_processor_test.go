@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"rinha-backend-golang/models"
+)
+
+// fakeProcessors is a ProcessorClient answering each processor's calls from a
+// script of errors, nil meaning the payment was charged. Once a script runs
+// out, calls succeed.
+type fakeProcessors struct {
+	mu     sync.Mutex
+	script map[string][]error
+	calls  map[string]int
+}
+
+func newFakeProcessors(script map[string][]error) *fakeProcessors {
+	return &fakeProcessors{script: script, calls: map[string]int{}}
+}
+
+func (f *fakeProcessors) Charge(_ context.Context, name, _ string, _ models.PaymentRequest) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[name]++
+	if len(f.script[name]) == 0 {
+		return true, nil
+	}
+	err := f.script[name][0]
+	f.script[name] = f.script[name][1:]
+	return err == nil, err
+}
+
+func (f *fakeProcessors) callCount(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[name]
+}
+
+// newTestWorker returns a Worker without database or HTTP client, charging
+// through processors.
+func newTestWorker(processors ProcessorClient) *Worker {
+	return &Worker{
+		processors: processors,
+		retryBudgets: map[string]*retryBudget{
+			"default":  newRetryBudget(1, 100),
+			"fallback": newRetryBudget(1, 100),
+		},
+		active:      newPaymentSet(),
+		scheduled:   newPaymentSet(),
+		unconfirmed: newUnconfirmedCharges(),
+		events:      newEventHub(),
+	}
+}
@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"encoding/json"
+
+	"rinha-backend-golang/models"
+)
+
+// encodeProcessorBody marshals req for a processor, renaming top-level JSON
+// fields according to mapping (internal name -> processor name). With no
+// mapping the payload is the plain models.PaymentRequest encoding.
+func encodeProcessorBody(req models.PaymentRequest, mapping map[string]string) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil || len(mapping) == 0 {
+		return body, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		if to, ok := mapping[k]; ok {
+			k = to
+		}
+		renamed[k] = v
+	}
+	return json.Marshal(renamed)
+}
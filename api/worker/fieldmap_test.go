@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/models"
+)
+
+func TestEncodeProcessorBody(t *testing.T) {
+	req := models.PaymentRequest{
+		CorrelationID: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3",
+		Amount:        "19.90",
+		Timestamp:     time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name     string
+		mapping  map[string]string
+		wantKeys string // sorted, comma-separated
+	}{
+		{"no mapping", nil, "amount,correlationId,timestamp"},
+		{"renamed fields", map[string]string{"correlationId": "id", "amount": "value"}, "id,timestamp,value"},
+		{"mapping for an absent field", map[string]string{"currency": "ccy"}, "amount,correlationId,timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := encodeProcessorBody(req, tt.mapping)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(body, &fields); err != nil {
+				t.Fatal(err)
+			}
+			keys := make([]string, 0, len(fields))
+			for k := range fields {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if got := strings.Join(keys, ","); got != tt.wantKeys {
+				t.Errorf("fields %s, want %s", got, tt.wantKeys)
+			}
+			// Renaming moves a value, it never changes it.
+			id, amount := "correlationId", "amount"
+			if to, ok := tt.mapping[id]; ok {
+				id = to
+			}
+			if to, ok := tt.mapping[amount]; ok {
+				amount = to
+			}
+			if got := string(fields[id]); got != `"`+req.CorrelationID+`"` {
+				t.Errorf("%s = %s", id, got)
+			}
+			if got := string(fields[amount]); got != "19.90" {
+				t.Errorf("%s = %s, want 19.90", amount, got)
+			}
+		})
+	}
+}
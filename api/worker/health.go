@@ -0,0 +1,214 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+var errMissingFailing = errors.New(`missing "failing" field`)
+
+// startHealthChecks polls both processors right away and then every
+// config.HealthCheckInterval, or only follows the elected leader's readings
+// when another worker holds the health lease. healthPolled is closed after
+// the first tick.
+func (w *Worker) startHealthChecks() {
+	ticker := time.NewTicker(config.HealthCheckInterval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		if w.leader != nil {
+			w.pollHealth()
+		} else {
+			w.checkAllProcessors()
+		}
+		if first {
+			close(w.healthPolled)
+		}
+		<-ticker.C
+	}
+}
+
+func (w *Worker) checkAllProcessors() {
+	w.checkProcessorHealth("default", config.ProcessorURL("default"))
+	w.checkProcessorHealth("fallback", config.ProcessorURL("fallback"))
+}
+
+// awaitFirstHealthPoll holds startup for up to config.HealthStartupGrace so
+// the first payments are routed on a real reading rather than the
+// HEALTH_FAILURE_POLICY guess.
+func (w *Worker) awaitFirstHealthPoll() {
+	if config.HealthStartupGrace <= 0 {
+		return
+	}
+	select {
+	case <-w.healthPolled:
+		logging.Infof("Worker: first processor health poll done")
+	case <-time.After(config.HealthStartupGrace):
+		logging.Warnf("Worker: first processor health poll still running after %s, starting anyway", config.HealthStartupGrace)
+	}
+}
+
+// healthReading is a point-in-time view of both processors' health.
+type healthReading struct {
+	defaultHealthy  bool
+	fallbackHealthy bool
+	at              time.Time
+}
+
+// processorHealth returns the health of both processors as used for routing.
+// With config.HealthCacheTTL set, every caller within the window gets the same
+// reading, so a health poll landing mid-window cannot make concurrent payments
+// route inconsistently. Reads never wait on an in-progress poll.
+func (w *Worker) processorHealth() (defaultHealthy, fallbackHealthy bool) {
+	if config.HealthCacheTTL <= 0 {
+		return w.currentHealth()
+	}
+	now := time.Now()
+	if cached := w.healthCache.Load(); cached != nil && now.Sub(cached.at) < config.HealthCacheTTL {
+		return cached.defaultHealthy, cached.fallbackHealthy
+	}
+	reading := &healthReading{at: now}
+	reading.defaultHealthy, reading.fallbackHealthy = w.currentHealth()
+	w.healthCache.Store(reading)
+	return reading.defaultHealthy, reading.fallbackHealthy
+}
+
+// currentHealth is the latest polled health, with simulated overrides applied.
+func (w *Worker) currentHealth() (defaultHealthy, fallbackHealthy bool) {
+	return applyForced(w.defaultHealthy.Load(), w.defaultForced.Load()),
+		applyForced(w.fallbackHealthy.Load(), w.fallbackForced.Load())
+}
+
+// setHealthUnknown applies config.HealthFailOpen when no health reading
+// could be obtained.
+func (w *Worker) setHealthUnknown(name string) {
+	w.setHealthy(name, config.HealthFailOpen)
+}
+
+// setHealthy sets a processor's health, clearing any degraded mark.
+func (w *Worker) setHealthy(name string, healthy bool) {
+	w.processorDegraded(name).Store(false)
+	if name == "default" {
+		w.defaultHealthy.Store(healthy)
+	} else {
+		w.fallbackHealthy.Store(healthy)
+	}
+}
+
+// healthURL is the health endpoint of the processor at base url, unless
+// overridden in config.ProcessorHealthURLs.
+func healthURL(name, url string) string {
+	if u := config.ProcessorHealthURLs[name]; u != "" {
+		return u
+	}
+	return url + "/payments/service-health"
+}
+
+// checkProcessorHealth polls a processor's health endpoint. Transport
+// failures leave the health unknown, resolved by config.HealthFailOpen;
+// non-200 responses mark the processor unhealthy; a 200
+// whose body does not match the expected schema is a schema failure, which is
+// unhealthy unless config.HealthTolerateMalformed is set.
+func (w *Worker) checkProcessorHealth(name, url string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	method, target := config.ProcessorHealthMethods[name], healthURL(name, url)
+	logging.Debugf("Worker: Checking health for %s with %s %s", name, method, target)
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		logging.Errorf("Worker: Error creating health check request for %s: %v", name, err)
+		w.setHealthUnknown(name)
+		return
+	}
+	config.SetOutboundHeaders(req.Header)
+	start := time.Now()
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		logging.Errorf("Worker: Health check transport failure for %s (fail-open=%t): %v", name, config.HealthFailOpen, err)
+		w.setHealthUnknown(name)
+		return
+	}
+	defer resp.Body.Close()
+	rtt := time.Since(start)
+
+	logging.Debugf("Worker: Health check for %s returned status: %d in %s", name, resp.StatusCode, rtt)
+
+	if resp.StatusCode != http.StatusOK {
+		logging.Errorf("Worker: Health check for %s failed with non-200 status: %d", name, resp.StatusCode)
+		w.setHealthy(name, false)
+		return
+	}
+
+	// Decode into a pointer so a body missing "failing" counts as malformed.
+	var healthResp struct {
+		Failing         *bool `json:"failing"`
+		MinResponseTime int64 `json:"minResponseTime"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&healthResp); err != nil || healthResp.Failing == nil {
+		if err == nil {
+			err = errMissingFailing
+		}
+		if config.HealthTolerateMalformed {
+			logging.Warnf("Worker: Health check schema failure for %s (treating as healthy but degraded): %v", name, err)
+			w.setState(name, stateDegraded)
+		} else {
+			logging.Errorf("Worker: Health check schema failure for %s: %v", name, err)
+			w.setHealthy(name, false)
+		}
+		return
+	}
+	logging.Debugf("Worker: Health check for %s - Failing: %t, MinResponseTime: %dms", name, *healthResp.Failing, healthResp.MinResponseTime)
+	if name == "default" {
+		w.defaultLatency.Store(healthResp.MinResponseTime)
+	} else {
+		w.fallbackLatency.Store(healthResp.MinResponseTime)
+	}
+	state := classifyHealth(*healthResp.Failing, rtt)
+	if state == stateDegraded {
+		logging.Warnf("Worker: Health check for %s took %s, marking it degraded", name, rtt)
+	}
+	w.setState(name, state)
+}
+
+// processorState is a processor's health as classified by one poll.
+type processorState int
+
+const (
+	stateDown processorState = iota
+	stateDegraded
+	stateHealthy
+)
+
+// classifyHealth classifies a well-formed health response that took rtt: up
+// but slower than config.HealthDegradedLatency is degraded.
+func classifyHealth(failing bool, rtt time.Duration) processorState {
+	switch {
+	case failing:
+		return stateDown
+	case config.HealthDegradedLatency > 0 && rtt > config.HealthDegradedLatency:
+		return stateDegraded
+	}
+	return stateHealthy
+}
+
+// setState records a classified poll. A degraded processor stays usable; it
+// is only tried after a healthy one.
+func (w *Worker) setState(name string, state processorState) {
+	w.setHealthy(name, state != stateDown)
+	if state == stateDegraded {
+		w.processorDegraded(name).Store(true)
+	}
+}
+
+func (w *Worker) processorDegraded(name string) *atomic.Bool {
+	if name == "default" {
+		return &w.defaultDegraded
+	}
+	return &w.fallbackDegraded
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+func TestClassifyHealth(t *testing.T) {
+	defer func(latency time.Duration) { config.HealthDegradedLatency = latency }(config.HealthDegradedLatency)
+
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		failing   bool
+		rtt       time.Duration
+		want      processorState
+	}{
+		{"failing", 100 * time.Millisecond, true, time.Millisecond, stateDown},
+		{"failing and slow", 100 * time.Millisecond, true, time.Second, stateDown},
+		{"fast", 100 * time.Millisecond, false, 50 * time.Millisecond, stateHealthy},
+		{"at the threshold", 100 * time.Millisecond, false, 100 * time.Millisecond, stateHealthy},
+		{"slow", 100 * time.Millisecond, false, 101 * time.Millisecond, stateDegraded},
+		{"no threshold", 0, false, time.Minute, stateHealthy},
+	}
+	for _, tt := range tests {
+		config.HealthDegradedLatency = tt.threshold
+		if got := classifyHealth(tt.failing, tt.rtt); got != tt.want {
+			t.Errorf("%s: classifyHealth = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestSetState walks a processor through the health states and checks that
+// degraded is cleared by any later poll that does not report it.
+func TestSetState(t *testing.T) {
+	w := newTestWorker(nil)
+	steps := []struct {
+		state                     processorState
+		wantHealthy, wantDegraded bool
+	}{
+		{stateHealthy, true, false},
+		{stateDegraded, true, true},
+		{stateDegraded, true, true},
+		{stateHealthy, true, false},
+		{stateDegraded, true, true},
+		{stateDown, false, false},
+	}
+	for i, s := range steps {
+		w.setState("fallback", s.state)
+		if got := w.fallbackHealthy.Load(); got != s.wantHealthy {
+			t.Errorf("step %d: healthy = %t, want %t", i, got, s.wantHealthy)
+		}
+		if got := w.fallbackDegraded.Load(); got != s.wantDegraded {
+			t.Errorf("step %d: degraded = %t, want %t", i, got, s.wantDegraded)
+		}
+		if w.defaultHealthy.Load() || w.defaultDegraded.Load() {
+			t.Errorf("step %d: default changed with fallback", i)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rinha-backend-golang/models"
+)
+
+// maxHourlyBuckets caps /payments-summary/hourly at about a month of hours.
+const maxHourlyBuckets = 24 * 31
+
+func (w *Worker) handleHourlySummary(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from != nil && to != nil && to.Sub(from.Truncate(time.Hour))/time.Hour >= maxHourlyBuckets {
+		http.Error(wr, fmt.Sprintf("range too large: at most %d hours", maxHourlyBuckets), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := summaryContext()
+	defer cancel()
+	// An open range is bounded by the LIMIT instead: one row past the cap
+	// means the data spans too many hours.
+	rows, err := w.readDB.Query(ctx, `SELECT date_trunc('hour', created_at) AS hour,
+            COUNT(*) FILTER (WHERE processor = 'default'),
+            COALESCE(SUM(amount) FILTER (WHERE processor = 'default'),0),
+            COUNT(*) FILTER (WHERE processor = 'fallback'),
+            COALESCE(SUM(amount) FILTER (WHERE processor = 'fallback'),0)
+        FROM payments WHERE status = 'processed' AND `+rangeFilter+`
+        GROUP BY hour ORDER BY hour LIMIT $3`, from, to, maxHourlyBuckets+1)
+	if err != nil {
+		summaryQueryFailed(ctx, wr, "hourly summary", err)
+		return
+	}
+	defer rows.Close()
+
+	hours := make([]models.HourlySummary, 0)
+	for rows.Next() {
+		var h models.HourlySummary
+		if err := rows.Scan(&h.Hour, &h.Default.TotalRequests, &h.Default.TotalAmount,
+			&h.Fallback.TotalRequests, &h.Fallback.TotalAmount); err != nil {
+			continue
+		}
+		hours = append(hours, h)
+	}
+	if err := rows.Err(); err != nil {
+		summaryQueryFailed(ctx, wr, "hourly summary", err)
+		return
+	}
+	if len(hours) > maxHourlyBuckets {
+		http.Error(wr, "range too large: narrow it with from and to", http.StatusBadRequest)
+		return
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(hours)
+}
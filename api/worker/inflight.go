@@ -0,0 +1,26 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"rinha-backend-golang/models"
+)
+
+// Inflight counts the payments this worker holds at each stage. All of them
+// are back to zero once the worker is idle and its batches are flushed.
+func (w *Worker) Inflight() models.InflightCounts {
+	c := models.InflightCounts{
+		Processing: int64(w.active.len()),
+		Scheduled:  int64(w.scheduled.len()),
+	}
+	if w.batcher != nil {
+		c.PendingInsert = w.batcher.waiting.Load()
+	}
+	return c
+}
+
+func (w *Worker) handleInflight(wr http.ResponseWriter, r *http.Request) {
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(w.Inflight())
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// insertResult tracks consecutive failures to record processed payments.
+// After config.InsertFailureLimit of them the worker stops charging: a
+// payment charged now would most likely be lost from the summary, breaking
+// consistency. The first successful write, or insert probe, resumes it.
+func (w *Worker) insertResult(err error) {
+	limit := int64(config.InsertFailureLimit)
+	if err == nil {
+		if n := w.insertFailures.Swap(0); limit > 0 && n >= limit {
+			logging.Infof("Worker: payment inserts succeed again, resuming processing")
+		}
+		return
+	}
+	if n := w.insertFailures.Add(1); limit > 0 && n == limit {
+		logging.Errorf("Worker: %d consecutive payment inserts failed, not charging payments until they succeed: %v", n, err)
+	}
+}
+
+// insertsFailing reports whether charging is stopped by insertResult.
+func (w *Worker) insertsFailing() bool {
+	return config.InsertFailureLimit > 0 && w.insertFailures.Load() >= int64(config.InsertFailureLimit)
+}
+
+// probeInserts makes a small write to see whether inserts work again, since
+// no payment is recorded while charging is stopped.
+func (w *Worker) probeInserts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := w.db.Exec(ctx, `INSERT INTO insert_probe (id, probed_at) VALUES (1, now())
+        ON CONFLICT (id) DO UPDATE SET probed_at = EXCLUDED.probed_at`)
+	if err != nil {
+		logging.Debugf("Worker: insert probe failed: %v", err)
+	}
+	w.insertResult(err)
+}
@@ -0,0 +1,115 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+)
+
+func TestInsertGuard(t *testing.T) {
+	defer func(limit int) { config.InsertFailureLimit = limit }(config.InsertFailureLimit)
+	failed := errors.New("insert failed")
+
+	tests := []struct {
+		name    string
+		limit   int
+		results []error
+		want    bool // insertsFailing afterwards
+	}{
+		{"disabled", 0, []error{failed, failed, failed}, false},
+		{"below the limit", 3, []error{failed, failed}, false},
+		{"at the limit", 3, []error{failed, failed, failed}, true},
+		{"success resets the count", 3, []error{failed, failed, nil, failed, failed}, false},
+		{"success resumes after the limit", 2, []error{failed, failed, failed, nil}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.InsertFailureLimit = tt.limit
+			w := newTestWorker(nil)
+			w.dbHealthy.Store(true)
+			for _, err := range tt.results {
+				w.insertResult(err)
+			}
+			if got := w.insertsFailing(); got != tt.want {
+				t.Errorf("insertsFailing = %t, want %t", got, tt.want)
+			}
+			if tt.want && w.Submit(payment("p1")) {
+				t.Error("Submit accepted a payment while inserts fail")
+			}
+		})
+	}
+}
+
+// TestInsertProbe stops charging on persistent insert failures, checks a
+// failed probe keeps it stopped and a successful one resumes it, charging the
+// payment held back meanwhile.
+func TestInsertProbe(t *testing.T) {
+	defer func(limit int) { config.InsertFailureLimit = limit }(config.InsertFailureLimit)
+	config.InsertFailureLimit = 2
+	setBatcherConfig(t)
+	backoff.SetJitter(backoff.JitterNone)
+	defer backoff.SetJitter(backoff.JitterFull)
+
+	fake := newFakeProcessors(nil)
+	outcomes := newFakeOutcomes()
+	w := newTestWorker(fake)
+	w.db = resettingPool(t)
+	w.seen = newBloomFilter(1024, 3)
+	w.batcher = outcomes.batcher()
+	w.defaultHealthy.Store(true)
+	w.inflight = make(chan struct{}, 1)
+	w.dbHealthy.Store(true)
+	w.insertResult(errors.New("insert failed"))
+	w.insertResult(errors.New("insert failed"))
+
+	w.probeInserts()
+	if !w.insertsFailing() {
+		t.Fatal("a failed probe resumed charging")
+	}
+	w.processPayment(payment("p1"))
+	if n := fake.callCount("default"); n != 0 {
+		t.Fatalf("processor calls = %d while inserts fail, want 0", n)
+	}
+	if n := w.scheduled.len(); n != 1 {
+		t.Fatalf("scheduled passes = %d, want the payment kept for later", n)
+	}
+
+	// probeInserts reports a successful probe through insertResult.
+	w.insertResult(nil)
+	if w.insertsFailing() {
+		t.Fatal("a successful probe did not resume charging")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for w.scheduled.len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("postponed pass did not start after charging resumed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case w.inflight <- struct{}{}:
+	case <-time.After(2 * time.Second):
+		t.Fatal("postponed pass did not finish")
+	}
+	w.batcher.close()
+
+	if got := outcomes.processor["p1"]; got != "default" {
+		t.Errorf("p1 recorded with %q, want default", got)
+	}
+}
+
+func TestInsertProbeDB(t *testing.T) {
+	defer func(limit int) { config.InsertFailureLimit = limit }(config.InsertFailureLimit)
+	config.InsertFailureLimit = 1
+	w := newTestWorker(nil)
+	w.db = testPool(t)
+	w.insertResult(errors.New("insert failed"))
+
+	w.probeInserts()
+	if w.insertsFailing() {
+		t.Error("a successful probe did not resume charging")
+	}
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// healthLeader elects one worker to poll processor health, through a single
+// lease row in health_leader. The holder renews the lease on every health
+// tick; once it stops, any other worker takes the lease over after
+// config.HealthLeaderTTL.
+type healthLeader struct {
+	db     *pgxpool.Pool
+	id     string
+	leader bool
+}
+
+func newHealthLeader(db *pgxpool.Pool) *healthLeader {
+	host, _ := os.Hostname()
+	return &healthLeader{db: db, id: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())}
+}
+
+// acquire takes or renews the lease, reporting whether this worker holds it.
+// The lease is only taken from another holder once it has expired.
+func (l *healthLeader) acquire(ctx context.Context) (bool, error) {
+	var holder string
+	err := l.db.QueryRow(ctx, `INSERT INTO health_leader (id, holder, expires_at)
+        VALUES (1, $1, now() + $2 * interval '1 millisecond')
+        ON CONFLICT (id) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+        WHERE health_leader.holder = $1 OR health_leader.expires_at < now()
+        RETURNING holder`, l.id, config.HealthLeaderTTL.Milliseconds()).Scan(&holder)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return holder == l.id, nil
+}
+
+// pollHealth runs one health tick under leader election: the leader polls the
+// processors and publishes the readings, the others load them. When the lease
+// cannot be checked at all the worker polls on its own, as it would without
+// election.
+func (w *Worker) pollHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	leader, err := w.leader.acquire(ctx)
+	cancel()
+	if err != nil {
+		logging.Errorf("Worker: health leader lease check failed, polling locally: %v", err)
+		w.checkAllProcessors()
+		return
+	}
+	if leader != w.leader.leader {
+		w.leader.leader = leader
+		if leader {
+			logging.Infof("Worker: became health check leader (%s)", w.leader.id)
+		} else {
+			logging.Infof("Worker: no longer health check leader (%s)", w.leader.id)
+		}
+	}
+	if leader {
+		w.checkAllProcessors()
+		w.publishHealth()
+		return
+	}
+	w.loadSharedHealth()
+}
+
+// publishHealth writes this worker's latest readings to processor_health.
+func (w *Worker) publishHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	batch := &pgx.Batch{}
+	for _, p := range []struct {
+		name     string
+		healthy  bool
+		degraded bool
+		latency  int64
+	}{
+		{"default", w.defaultHealthy.Load(), w.defaultDegraded.Load(), w.defaultLatency.Load()},
+		{"fallback", w.fallbackHealthy.Load(), w.fallbackDegraded.Load(), w.fallbackLatency.Load()},
+	} {
+		batch.Queue(`INSERT INTO processor_health (name, healthy, degraded, min_response_time, checked_at)
+            VALUES ($1, $2, $3, $4, now())
+            ON CONFLICT (name) DO UPDATE SET healthy = EXCLUDED.healthy, degraded = EXCLUDED.degraded,
+                min_response_time = EXCLUDED.min_response_time, checked_at = EXCLUDED.checked_at`,
+			p.name, p.healthy, p.degraded, p.latency)
+	}
+	if err := w.db.SendBatch(ctx, batch).Close(); err != nil {
+		logging.Errorf("Worker: could not publish processor health: %v", err)
+	}
+}
+
+// loadSharedHealth adopts the leader's readings. Readings older than the
+// lease TTL are ignored, since their leader is gone and a new one has not
+// published yet; the current health then stands.
+func (w *Worker) loadSharedHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	rows, err := w.db.Query(ctx, `SELECT name, healthy, degraded, min_response_time FROM processor_health
+        WHERE checked_at > now() - $1 * interval '1 millisecond'`, config.HealthLeaderTTL.Milliseconds())
+	if err != nil {
+		logging.Errorf("Worker: could not load shared processor health: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var healthy, degraded bool
+		var latency int64
+		if err := rows.Scan(&name, &healthy, &degraded, &latency); err != nil {
+			continue
+		}
+		logging.Debugf("Worker: shared health for %s - healthy: %t, degraded: %t, MinResponseTime: %dms", name, healthy, degraded, latency)
+		if name == "default" {
+			w.defaultLatency.Store(latency)
+		} else {
+			w.fallbackLatency.Store(latency)
+		}
+		switch {
+		case !healthy:
+			w.setState(name, stateDown)
+		case degraded:
+			w.setState(name, stateDegraded)
+		default:
+			w.setState(name, stateHealthy)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"sync"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+// orderedQueues serializes payments sharing an ordering key: each key with
+// pending payments has one runner goroutine working through its queue in
+// submission order, while different keys still run in parallel. A key is
+// present in the map exactly while its runner is alive.
+type orderedQueues struct {
+	mu     sync.Mutex
+	queues map[string][]models.PaymentRequest
+}
+
+func newOrderedQueues() *orderedQueues {
+	return &orderedQueues{queues: make(map[string][]models.PaymentRequest)}
+}
+
+// push appends req to key's queue and reports whether the caller has to
+// start a runner for it.
+func (o *orderedQueues) push(key string, req models.PaymentRequest) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	q, running := o.queues[key]
+	o.queues[key] = append(q, req)
+	return !running
+}
+
+// pop takes the head of key's queue. When the queue is empty the key is
+// removed and the runner must exit.
+func (o *orderedQueues) pop(key string) (models.PaymentRequest, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	q := o.queues[key]
+	if len(q) == 0 {
+		delete(o.queues, key)
+		return models.PaymentRequest{}, false
+	}
+	req := q[0]
+	o.queues[key] = q[1:]
+	return req, true
+}
+
+// orderingKey is the key whose payments must be processed in order: the
+// client's X-Partition-Key, else the first config.OrderingPrefixLen bytes of
+// the correlationId. Empty means no ordering.
+func orderingKey(req models.PaymentRequest) string {
+	if req.PartitionKey != "" {
+		return req.PartitionKey
+	}
+	if n := config.OrderingPrefixLen; n > 0 && len(req.CorrelationID) >= n {
+		return req.CorrelationID[:n]
+	}
+	return ""
+}
+
+// runOrdered processes key's queue until it is empty. Every queued payment
+// already holds an in-flight slot, released once it has been processed.
+// Ordering covers the first processing pass; a payment re-processed after a
+// failure runs whenever its backoff expires.
+func (w *Worker) runOrdered(key string) {
+	for {
+		req, ok := w.ordered.pop(key)
+		if !ok {
+			return
+		}
+		w.scheduled.remove(req.CorrelationID)
+		w.processPayment(req)
+		w.releaseSlot()
+	}
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/models"
+)
+
+func TestOrderedQueues(t *testing.T) {
+	o := newOrderedQueues()
+	if !o.push("k", payment("p1")) {
+		t.Error("first push did not ask for a runner")
+	}
+	if o.push("k", payment("p2")) {
+		t.Error("second push asked for another runner")
+	}
+	if !o.push("other", payment("q1")) {
+		t.Error("push on another key did not ask for its runner")
+	}
+	for _, want := range []string{"p1", "p2"} {
+		if req, ok := o.pop("k"); !ok || req.CorrelationID != want {
+			t.Errorf("pop = %s, %t; want %s", req.CorrelationID, ok, want)
+		}
+	}
+	if _, ok := o.pop("k"); ok {
+		t.Error("pop on an empty queue returned a payment")
+	}
+	if !o.push("k", payment("p3")) {
+		t.Error("push after the runner exited did not ask for a new one")
+	}
+}
+
+// orderRecorder is a ProcessorClient recording the order in which payments
+// are charged, slow enough for unordered processing to interleave them.
+type orderRecorder struct {
+	mu      sync.Mutex
+	charged []string
+}
+
+func (r *orderRecorder) Charge(_ context.Context, _, _ string, req models.PaymentRequest) (bool, error) {
+	time.Sleep(time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.charged = append(r.charged, req.CorrelationID)
+	return true, nil
+}
+
+func (r *orderRecorder) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.charged...)
+}
+
+// TestOrderedProcessing submits interleaved payments for two keys and checks
+// each key's payments are charged in submission order.
+func TestOrderedProcessing(t *testing.T) {
+	setBatcherConfig(t)
+	const perKey = 10
+	rec := &orderRecorder{}
+	w := newTestWorker(rec)
+	w.seen = newBloomFilter(1024, 3)
+	w.batcher = newFakeOutcomes().batcher()
+	w.ordered = newOrderedQueues()
+	// Each payment holds a slot until processed, which lets the test wait
+	// for all of them below.
+	w.inflight = make(chan struct{}, 2*perKey)
+	w.dbHealthy.Store(true)
+	w.defaultHealthy.Store(true)
+
+	for i := 0; i < perKey; i++ {
+		for _, key := range []string{"a", "b"} {
+			req := payment(fmt.Sprintf("%s%d", key, i))
+			req.PartitionKey = key
+			if !w.Submit(req) {
+				t.Fatalf("Submit refused %s", req.CorrelationID)
+			}
+		}
+	}
+	for i := 0; i < 2*perKey; i++ {
+		select {
+		case w.inflight <- struct{}{}:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("charged %d of %d payments", len(rec.list()), 2*perKey)
+		}
+	}
+	w.batcher.close()
+
+	next := map[string]int{}
+	for _, id := range rec.list() {
+		key := id[:1]
+		if want := fmt.Sprintf("%s%d", key, next[key]); id != want {
+			t.Fatalf("charged %s, want %s next: %v", id, want, rec.list())
+		}
+		next[key]++
+	}
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"rinha-backend-golang/models"
+)
+
+var (
+	errNoHealthyProcessor = errors.New("no healthy processor")
+	errPaymentDeclined    = fmt.Errorf("%w: processor declined payment", ErrProcessorBadResponse)
+)
+
+// recordOutcome writes the payment's current processing status. The row may
+// already exist (logged as received by the gateway, or from an earlier failed
+// pass), so it is updated in place unless it was already processed; only
+// when no row exists is one inserted. This avoids ON CONFLICT, whose target
+// differs between the plain and the partitioned table.
+func (w *Worker) recordOutcome(ctx context.Context, req models.PaymentRequest, status string, lastErr error) error {
+	var errText *string
+	if lastErr != nil {
+		msg := lastErr.Error()
+		errText = &msg
+	}
+	var processor *string
+	if req.Processor != "" {
+		processor = &req.Processor
+	}
+	attempts := req.Attempts + 1
+	if status != models.StatusProcessed {
+		// Failure outcomes are recorded after Attempts was advanced.
+		attempts = req.Attempts
+	}
+
+	tag, err := w.db.Exec(ctx, `UPDATE payments SET amount = $2, processor = $3, status = $4, attempts = $5, last_error = $6
+        WHERE correlation_id = $1 AND status <> 'processed'`,
+		req.CorrelationID, req.Amount, processor, status, attempts, errText)
+	if err != nil || tag.RowsAffected() > 0 {
+		return err
+	}
+	_, err = w.db.Exec(ctx, `INSERT INTO payments (correlation_id, amount, processor, status, attempts, last_error)
+        VALUES ($1,$2,$3,$4,$5,$6)`,
+		req.CorrelationID, req.Amount, processor, status, attempts, errText)
+	return err
+}
+
+// upsertProcessed records req as processed in one statement, for
+// config.DedupUpsert. It reports whether this call recorded it: false means
+// the row was already processed, by an earlier pass or a concurrent one, and
+// the payment must not be counted again.
+func (w *Worker) upsertProcessed(ctx context.Context, req models.PaymentRequest) (bool, error) {
+	var id string
+	err := w.db.QueryRow(ctx, `INSERT INTO payments (correlation_id, amount, processor, status, attempts)
+        VALUES ($1,$2,$3,'processed',$4)
+        ON CONFLICT (correlation_id) DO UPDATE SET amount = EXCLUDED.amount, processor = EXCLUDED.processor,
+            status = EXCLUDED.status, attempts = EXCLUDED.attempts, last_error = NULL
+        WHERE payments.status <> 'processed'
+        RETURNING correlation_id`,
+		req.CorrelationID, req.Amount, req.Processor, req.Attempts+1).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
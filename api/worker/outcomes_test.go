@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"rinha-backend-golang/models"
+)
+
+func TestRecordOutcomeTransitions(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	w := newTestWorker(nil)
+	w.db = pool
+
+	tests := []struct {
+		name         string
+		existing     string // status of the row already there, "" for none
+		status       string
+		attempts     int // req.Attempts when recorded
+		wantStatus   string
+		wantAttempts int
+		wantError    bool
+		mayFail      bool // the fallback INSERT may hit the processed row
+	}{
+		{"first pass processed", "", models.StatusProcessed, 0, models.StatusProcessed, 1, false, false},
+		{"received then processed", models.StatusReceived, models.StatusProcessed, 0, models.StatusProcessed, 1, false, false},
+		{"received then retrying", models.StatusReceived, models.StatusRetrying, 1, models.StatusRetrying, 1, true, false},
+		{"retrying then processed", models.StatusRetrying, models.StatusProcessed, 1, models.StatusProcessed, 2, false, false},
+		{"retrying then failed", models.StatusRetrying, models.StatusFailed, 3, models.StatusFailed, 3, true, false},
+		{"processed is final", models.StatusProcessed, models.StatusRetrying, 1, models.StatusProcessed, 1, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := pool.Exec(ctx, "TRUNCATE payments"); err != nil {
+				t.Fatal(err)
+			}
+			req := payment("p1")
+			if tt.existing != "" {
+				if _, err := pool.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor, status) VALUES ($1,$2,NULL,$3)",
+					req.CorrelationID, req.Amount, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			req.Attempts = tt.attempts
+			var lastErr error
+			if tt.status != models.StatusProcessed {
+				lastErr = errors.New("processor unavailable")
+			}
+			if err := w.recordOutcome(ctx, req, tt.status, lastErr); err != nil && !tt.mayFail {
+				t.Fatal(err)
+			}
+
+			var status string
+			var attempts, rows int
+			var lastError *string
+			if err := pool.QueryRow(ctx, "SELECT status, attempts, last_error, (SELECT count(*) FROM payments) FROM payments WHERE correlation_id = $1",
+				req.CorrelationID).Scan(&status, &attempts, &lastError, &rows); err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.wantStatus || attempts != tt.wantAttempts {
+				t.Errorf("status %s after %d attempts, want %s after %d", status, attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if (lastError != nil) != tt.wantError {
+				t.Errorf("last_error = %v, want set: %v", lastError, tt.wantError)
+			}
+			if rows != 1 {
+				t.Errorf("%d rows, want 1", rows)
+			}
+		})
+	}
+}
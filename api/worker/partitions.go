@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// startPartitionMaintenance keeps the daily partitions of the payments table
+// one day ahead and drops the ones past the retention window.
+func (w *Worker) startPartitionMaintenance() {
+	ticker := time.NewTicker(config.PartitionMaintenanceInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.maintainPartitions()
+	}
+}
+
+func (w *Worker) maintainPartitions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	now := time.Now()
+	if err := config.EnsurePaymentPartitions(ctx, w.db, now); err != nil {
+		logging.Errorf("Worker: partition maintenance error: %v", err)
+	}
+	if _, err := config.DropExpiredPartitions(ctx, w.db, now); err != nil {
+		logging.Errorf("Worker: partition cleanup error: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+// ProcessorClient charges a payment on a payment processor. name identifies
+// the processor ("default" or "fallback") for per-processor settings and url
+// is its base URL. It returns true only when the processor accepted the
+// payment; otherwise the error says why, wrapping one of the ErrProcessor
+// classes below.
+type ProcessorClient interface {
+	Charge(ctx context.Context, name, url string, req models.PaymentRequest) (bool, error)
+}
+
+// Processor failure classes. Charge errors wrap one of them, so callers can
+// choose a policy per class with errors.Is.
+var (
+	ErrProcessorTimeout     = errors.New("processor timeout")
+	ErrProcessorUnavailable = errors.New("processor unavailable")           // transport failure or 5xx
+	ErrProcessorRateLimited = errors.New("processor rate limited")          // 429
+	ErrProcessorDuplicate   = errors.New("processor already holds payment") // 422
+	ErrProcessorRejected    = errors.New("processor rejected payment")      // any other 4xx
+	ErrProcessorBadResponse = errors.New("unexpected processor response")   // unexpected 2xx status or body
+)
+
+// retryable reports whether another attempt on the same processor may
+// succeed. A rate-limited call would only be limited again, and a bad
+// response may already have been charged.
+func retryable(err error) bool {
+	return errors.Is(err, ErrProcessorTimeout) || errors.Is(err, ErrProcessorUnavailable)
+}
+
+// canFallBack reports whether the payment may be sent to the other processor
+// after err. A rejection is final: the processor checked the payment and
+// refused it. So is a duplicate: the Rinha processors answer 422 when they
+// already hold that correlationId, i.e. the payment was charged there.
+func canFallBack(err error) bool {
+	return !errors.Is(err, ErrProcessorRejected) && !errors.Is(err, ErrProcessorDuplicate)
+}
+
+// classifyTransportError wraps an error from http.Client.Do.
+func classifyTransportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %v", ErrProcessorTimeout, err)
+	}
+	return fmt.Errorf("%w: %v", ErrProcessorUnavailable, err)
+}
+
+// classifyStatus wraps a response status outside the processor's success
+// codes. A 2xx there means the processor may have taken the payment, so it
+// is a bad response rather than a retryable failure.
+func classifyStatus(code int) error {
+	switch {
+	case code >= 200 && code < 300:
+		return fmt.Errorf("%w: unexpected status %d", ErrProcessorBadResponse, code)
+	case code == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: status %d", ErrProcessorRateLimited, code)
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: status %d", ErrProcessorTimeout, code)
+	case code == http.StatusUnprocessableEntity:
+		return fmt.Errorf("%w: status %d", ErrProcessorDuplicate, code)
+	case code >= 400 && code < 500:
+		return fmt.Errorf("%w: status %d", ErrProcessorRejected, code)
+	default:
+		return fmt.Errorf("%w: non-OK status %d", ErrProcessorUnavailable, code)
+	}
+}
+
+// httpProcessorClient is the ProcessorClient talking to the real processors'
+// POST /payments endpoint.
+type httpProcessorClient struct {
+	client *http.Client
+}
+
+func (c *httpProcessorClient) Charge(ctx context.Context, name, url string, req models.PaymentRequest) (bool, error) {
+	// Per attempt; processPayment's context carries the overall cap.
+	ctx, cancel := context.WithTimeout(ctx, config.ProcessorAttemptTimeout)
+	defer cancel()
+
+	reqBody, err := encodeProcessorBody(req, config.ProcessorFieldMaps[name])
+	if err != nil {
+		return false, fmt.Errorf("marshalling request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url+"/payments", bytes.NewReader(reqBody))
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	config.SetOutboundHeaders(httpReq.Header)
+	httpReq.Header.Set("Content-Type", "application/json")
+	if sig := signBody(name, reqBody); sig != "" {
+		httpReq.Header.Set(signatureHeader, sig)
+	}
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return false, classifyTransportError(err)
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(config.ProcessorSuccessCodes[name], resp.StatusCode) {
+		return false, classifyStatus(resp.StatusCode)
+	}
+
+	// Decode response body to check for success message
+	var processorResp struct {
+		Message string `json:"message"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&processorResp); err != nil {
+		return false, fmt.Errorf("%w: decoding response: %v", ErrProcessorBadResponse, err)
+	}
+
+	if processorResp.Message != "payment processed successfully" {
+		return false, fmt.Errorf("%w: unexpected message '%s'", ErrProcessorBadResponse, processorResp.Message)
+	}
+	return true, nil
+}
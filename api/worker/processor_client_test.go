@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"rinha-backend-golang/config"
+)
+
+func TestClassifyStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{http.StatusTooManyRequests, ErrProcessorRateLimited},
+		{http.StatusRequestTimeout, ErrProcessorTimeout},
+		{http.StatusGatewayTimeout, ErrProcessorTimeout},
+		{http.StatusUnprocessableEntity, ErrProcessorDuplicate},
+		{http.StatusBadRequest, ErrProcessorRejected},
+		{http.StatusConflict, ErrProcessorRejected},
+		{http.StatusInternalServerError, ErrProcessorUnavailable},
+		{http.StatusServiceUnavailable, ErrProcessorUnavailable},
+		{http.StatusCreated, ErrProcessorBadResponse},
+		{http.StatusNoContent, ErrProcessorBadResponse},
+	}
+	for _, tt := range tests {
+		if err := classifyStatus(tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("classifyStatus(%d) = %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestChargeDuplicate(t *testing.T) {
+	timeout := fmt.Errorf("%w: deadline exceeded", ErrProcessorTimeout)
+	duplicate := classifyStatus(http.StatusUnprocessableEntity)
+	tests := []struct {
+		name    string
+		retries int
+		script  []error
+		wantErr error
+	}{
+		{"422 after a timed-out attempt is charged", 1, []error{timeout, duplicate}, nil},
+		{"422 without a timed-out attempt", 1, []error{duplicate}, ErrProcessorDuplicate},
+		{"timeout without retries", 0, []error{timeout}, ErrProcessorTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retries := config.ProcessorRetries
+			config.ProcessorRetries = tt.retries
+			defer func() { config.ProcessorRetries = retries }()
+			w := newTestWorker(newFakeProcessors(map[string][]error{"default": tt.script}))
+			req := payment("p1")
+			err := w.chargeWithRetries(context.Background(), "default", "http://default", req)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("chargeWithRetries = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChargeDuplicateAcrossPasses(t *testing.T) {
+	timeout := fmt.Errorf("%w: deadline exceeded", ErrProcessorTimeout)
+	w := newTestWorker(newFakeProcessors(map[string][]error{
+		"default":  {timeout, classifyStatus(http.StatusUnprocessableEntity)},
+		"fallback": {classifyStatus(http.StatusUnprocessableEntity)},
+	}))
+	req := payment("p1")
+	ctx := context.Background()
+	if err := w.callProcessor(ctx, "default", "http://default", req); !errors.Is(err, ErrProcessorTimeout) {
+		t.Fatalf("first pass = %v, want a timeout", err)
+	}
+	// The fallback never timed out, so its 422 is not ours.
+	if err := w.callProcessor(ctx, "fallback", "http://fallback", req); !errors.Is(err, ErrProcessorDuplicate) {
+		t.Fatalf("fallback = %v, want a duplicate", err)
+	}
+	if err := w.callProcessor(ctx, "default", "http://default", req); err != nil {
+		t.Fatalf("second pass = %v, want the payment counted as charged", err)
+	}
+}
+
+func TestRetryOrDeadLetterDuplicate(t *testing.T) {
+	w := newTestWorker(nil)
+	// Without a database, recording a failure or dead-lettering would panic.
+	w.retryOrDeadLetter(payment("p1"), classifyStatus(http.StatusUnprocessableEntity))
+	if n := w.scheduled.len(); n != 0 {
+		t.Errorf("scheduled passes = %d, want 0", n)
+	}
+}
+
+func TestCanFallBack(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{classifyStatus(http.StatusUnprocessableEntity), false},
+		{classifyStatus(http.StatusBadRequest), false},
+		{classifyStatus(http.StatusInternalServerError), true},
+		{fmt.Errorf("%w: x", ErrProcessorTimeout), true},
+		{errNoHealthyProcessor, true},
+	}
+	for _, tt := range tests {
+		if got := canFallBack(tt.err); got != tt.want {
+			t.Errorf("canFallBack(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
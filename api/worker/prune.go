@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// startPaymentPruner deletes payments older than config.PaymentRetention
+// every config.PaymentPruneInterval.
+func (w *Worker) startPaymentPruner() {
+	ticker := time.NewTicker(config.PaymentPruneInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !w.dbHealthy.Load() {
+			continue
+		}
+		total := 0
+		for {
+			n, err := w.prunePayments(context.Background())
+			if err != nil {
+				logging.Errorf("Worker: payment pruning error: %v", err)
+				break
+			}
+			total += n
+			if n < config.PaymentPruneBatch {
+				break
+			}
+		}
+		if total > 0 {
+			logging.Infof("Worker: pruned %d payments older than %s", total, config.PaymentRetention)
+		}
+	}
+}
+
+// prunePayments deletes one batch of processed or failed payments past the
+// retention window and returns how many it deleted. Small batches keep each
+// statement's locks short. The processed ones are added to
+// payments_pruned_totals in the same statement, so the summary never drops
+// or double-counts them.
+func (w *Worker) prunePayments(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	var n int
+	err := w.db.QueryRow(ctx, `WITH pruned AS (
+            DELETE FROM payments WHERE (correlation_id, created_at) IN (
+                SELECT correlation_id, created_at FROM payments
+                WHERE status IN ('processed', 'failed') AND created_at < now() - $1::interval
+                LIMIT $2)
+            RETURNING processor, amount, status
+        ), folded AS (
+            INSERT INTO payments_pruned_totals AS t (processor, total_requests, total_amount)
+            SELECT COALESCE(processor, ''), COUNT(*), COALESCE(SUM(amount), 0) FROM pruned
+            WHERE status = 'processed' GROUP BY COALESCE(processor, '')
+            ON CONFLICT (processor) DO UPDATE SET total_requests = t.total_requests + EXCLUDED.total_requests,
+                total_amount = t.total_amount + EXCLUDED.total_amount
+        )
+        SELECT COUNT(*) FROM pruned`, config.PaymentRetention, config.PaymentPruneBatch).Scan(&n)
+	return n, err
+}
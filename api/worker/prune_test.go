@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+func TestPrunePayments(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	defer func(retention time.Duration, batch int) {
+		config.PaymentRetention, config.PaymentPruneBatch = retention, batch
+	}(config.PaymentRetention, config.PaymentPruneBatch)
+	config.PaymentRetention = 24 * time.Hour
+	config.PaymentPruneBatch = 2
+
+	now := time.Now()
+	rows := []struct {
+		id, processor, status string
+		amount                string
+		age                   time.Duration
+		wantKept              bool
+	}{
+		{"old-default", "default", "processed", "10", 48 * time.Hour, false},
+		{"old-default-2", "default", "processed", "15", 30 * time.Hour, false},
+		{"old-fallback", "fallback", "processed", "5", 48 * time.Hour, false},
+		{"old-failed", "default", "failed", "99", 48 * time.Hour, false},
+		{"old-retrying", "default", "retrying", "7", 48 * time.Hour, true},
+		{"recent", "default", "processed", "20", time.Hour, true},
+	}
+	for _, r := range rows {
+		if _, err := pool.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor, status, created_at) VALUES ($1,$2,$3,$4,$5)",
+			r.id, r.amount, r.processor, r.status, now.Add(-r.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := querySummary(ctx, pool, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestWorker(nil)
+	w.db = pool
+	total := 0
+	for {
+		n, err := w.prunePayments(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += n
+		if n < config.PaymentPruneBatch {
+			break
+		}
+	}
+	if total != 4 {
+		t.Errorf("pruned %d payments, want 4", total)
+	}
+	for _, r := range rows {
+		var kept bool
+		if err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM payments WHERE correlation_id = $1)", r.id).Scan(&kept); err != nil {
+			t.Fatal(err)
+		}
+		if kept != r.wantKept {
+			t.Errorf("%s kept = %t, want %t", r.id, kept, r.wantKept)
+		}
+	}
+
+	after, err := querySummary(ctx, pool, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("summary after pruning = %+v, want %+v", after, before)
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// startPuller claims payments from payment_queue in pull mode. A batch is only
+// claimed once the previous one has been fully processed and never exceeds the
+// free in-flight slots, so each worker takes work at its own pace.
+func (w *Worker) startPuller() {
+	for !w.stopping.Load() {
+		n := w.pullBatch()
+		if n == 0 {
+			time.Sleep(config.PullIdleInterval)
+		}
+	}
+}
+
+// pullBatch claims and processes one batch, returning how many payments it
+// claimed. Claimed rows are deleted on claim, so as in push mode a payment is
+// lost if the worker crashes mid-processing (a graceful shutdown saves it to
+// payment_outbox).
+func (w *Worker) pullBatch() int {
+	limit := config.PullBatchSize
+	if w.inflight != nil {
+		if free := cap(w.inflight) - len(w.inflight); free < limit {
+			limit = free
+		}
+	}
+	if limit <= 0 {
+		return 0
+	}
+
+	rows, err := w.db.Query(context.Background(), `DELETE FROM payment_queue
+        WHERE id IN (SELECT id FROM payment_queue ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+        RETURNING correlation_id, amount::text, enqueued_at`, limit)
+	if err != nil {
+		logging.Errorf("Worker: pull batch error: %v", err)
+		return 0
+	}
+	var batch []models.PaymentRequest
+	for rows.Next() {
+		var req models.PaymentRequest
+		if err := rows.Scan(&req.CorrelationID, &req.Amount, &req.Timestamp); err != nil {
+			logging.Errorf("Worker: pull batch scan error: %v", err)
+			continue
+		}
+		batch = append(batch, req)
+	}
+	rows.Close()
+
+	var wg sync.WaitGroup
+	for _, req := range batch {
+		if !w.acquireSlot() {
+			// Slots were counted before claiming; only other callers of
+			// acquireSlot can race us here, so wait for it.
+			w.inflight <- struct{}{}
+		}
+		wg.Add(1)
+		go func(req models.PaymentRequest) {
+			defer wg.Done()
+			defer w.releaseSlot()
+			w.processPayment(req)
+		}(req)
+	}
+	wg.Wait()
+	return len(batch)
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+// slowProcessors charges through fakeProcessors after a fixed delay.
+type slowProcessors struct {
+	*fakeProcessors
+	delay time.Duration
+}
+
+func (s slowProcessors) Charge(ctx context.Context, name, url string, req models.PaymentRequest) (bool, error) {
+	time.Sleep(s.delay)
+	return s.fakeProcessors.Charge(ctx, name, url, req)
+}
+
+// TestPullPace runs a fast and a slow worker against one payment_queue and
+// checks each claims work at its own pace.
+func TestPullPace(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, "TRUNCATE payment_queue"); err != nil {
+		t.Fatal(err)
+	}
+	setBatcherConfig(t)
+	defer func(size int) { config.PullBatchSize = size }(config.PullBatchSize)
+	config.PullBatchSize = 4
+	const payments = 100
+	for i := 0; i < payments; i++ {
+		if _, err := pool.Exec(ctx, "INSERT INTO payment_queue (correlation_id, amount) VALUES ($1, 10)", fmt.Sprintf("p%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	worker := func(delay time.Duration) (*Worker, *fakeProcessors) {
+		fake := newFakeProcessors(nil)
+		w := newTestWorker(slowProcessors{fake, delay})
+		w.db = pool
+		w.seen = newBloomFilter(1024, 3)
+		w.batcher = newFakeOutcomes().batcher()
+		w.inflight = make(chan struct{}, 4)
+		w.dbHealthy.Store(true)
+		w.defaultHealthy.Store(true)
+		return w, fake
+	}
+	fast, fastCalls := worker(time.Millisecond)
+	slow, slowCalls := worker(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for _, w := range []*Worker{fast, slow} {
+		wg.Add(1)
+		go func(w *Worker) {
+			defer wg.Done()
+			for w.pullBatch() > 0 {
+			}
+			w.batcher.close()
+		}(w)
+	}
+	wg.Wait()
+
+	f, s := fastCalls.callCount("default"), slowCalls.callCount("default")
+	if f+s != payments {
+		t.Fatalf("charged %d payments, want %d", f+s, payments)
+	}
+	if s >= f {
+		t.Errorf("slow worker pulled %d payments, fast one %d", s, f)
+	}
+}
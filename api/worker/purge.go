@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"rinha-backend-golang/logging"
+)
+
+// startupPurgeTables is everything a benchmark run leaves behind: payments
+// and their totals, the queues and outbox, and the per-payment side tables.
+// Health and leader state are kept.
+var startupPurgeTables = []string{
+	"payments",
+	"payments_pruned_totals",
+	"payment_queue",
+	"payment_outbox",
+	"payment_dead_letters",
+	"payment_conflicts",
+	"idempotency_keys",
+	"raw_payloads",
+	"summary_snapshots",
+}
+
+// purgeOnStart empties startupPurgeTables for config.PurgeOnStart. It runs
+// before the worker takes any payment, so a failure is fatal rather than
+// leaving a benchmark to run on top of stale rows.
+func (w *Worker) purgeOnStart() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := w.db.Exec(ctx, "TRUNCATE "+strings.Join(startupPurgeTables, ", ")); err != nil {
+		log.Fatalf("Worker: PURGE_ON_START failed: %v", err)
+	}
+	logging.Warnf("Worker: PURGE_ON_START truncated %s", strings.Join(startupPurgeTables, ", "))
+}
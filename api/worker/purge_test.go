@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+	"testing"
+)
+
+// TestPurgeOnStart checks the startup purge PURGE_ON_START runs leaves every
+// benchmark table empty.
+func TestPurgeOnStart(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	for _, sql := range []string{
+		"INSERT INTO payments (correlation_id, amount, processor) VALUES ('p1', 10, 'default')",
+		"INSERT INTO payments_pruned_totals (processor, total_requests, total_amount) VALUES ('default', 1, 10)",
+	} {
+		if _, err := pool.Exec(ctx, sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := newTestWorker(nil)
+	w.db = pool
+	w.purgeOnStart()
+
+	for _, table := range startupPurgeTables {
+		var n int
+		if err := pool.QueryRow(ctx, "SELECT count(*) FROM "+table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%s has %d rows after the purge", table, n)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// parseTimeRange reads the optional from/to query parameters (RFC 3339). A nil
+// bound means the range is open on that side, which maps to a NULL query
+// argument. A closed range longer than config.SummaryMaxRange is an error.
+func parseTimeRange(r *http.Request) (from, to *time.Time, err error) {
+	q := r.URL.Query()
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid from: %w", err)
+		}
+		from = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid to: %w", err)
+		}
+		to = &t
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, fmt.Errorf("to is before from")
+	}
+	if from != nil && to != nil && config.SummaryMaxRange > 0 && to.Sub(*from) > config.SummaryMaxRange {
+		return nil, nil, fmt.Errorf("range too large: at most %d days", config.SummaryMaxRange/(24*time.Hour))
+	}
+	return from, to, nil
+}
+
+// rangeFilter is the WHERE clause matching parseTimeRange's bounds passed as
+// the first two query arguments.
+var rangeFilter = rangeFilterOn("created_at")
+
+// rangeFilterOn is rangeFilter for an arbitrary timestamp column.
+func rangeFilterOn(column string) string {
+	return "($1::timestamptz IS NULL OR " + column + " >= $1) AND ($2::timestamptz IS NULL OR " + column + " <= $2)"
+}
+
+// summaryContext bounds one summary or time-series query by
+// config.SummaryQueryTimeout. pgx cancels the statement on the server when
+// the deadline passes.
+func summaryContext() (context.Context, context.CancelFunc) {
+	if config.SummaryQueryTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), config.SummaryQueryTimeout)
+}
+
+// summaryQueryFailed answers a failed summary or time-series query, with 503
+// when it was cancelled by config.SummaryQueryTimeout.
+func summaryQueryFailed(ctx context.Context, wr http.ResponseWriter, what string, err error) {
+	if ctx.Err() == context.DeadlineExceeded {
+		logging.Warnf("Worker: %s query exceeded %s", what, config.SummaryQueryTimeout)
+		http.Error(wr, "query timed out, narrow the range", http.StatusServiceUnavailable)
+		return
+	}
+	logging.Errorf("Worker: %s query error: %v", what, err)
+	http.Error(wr, "db error", http.StatusInternalServerError)
+}
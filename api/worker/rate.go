@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// rateWindow is how many whole seconds /payments-summary?rate=true averages.
+const rateWindow = 10
+
+// rateCounter counts events per second in a ring of rateWindow+1 slots, one
+// for the second in progress. Each slot packs its second (low 32 bits of the
+// Unix time) and count into one word, so recording is a lock-free CAS loop
+// and a slot left over from an earlier lap is reset by the first add of its
+// new second.
+type rateCounter struct {
+	slots [rateWindow + 1]atomic.Uint64
+}
+
+func (c *rateCounter) add(now time.Time) {
+	sec := uint64(uint32(now.Unix()))
+	slot := &c.slots[now.Unix()%int64(len(c.slots))]
+	for {
+		old := slot.Load()
+		next := sec<<32 | 1
+		if old>>32 == sec {
+			next = old + 1
+		}
+		if slot.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// perSecond averages the rateWindow whole seconds before now's.
+func (c *rateCounter) perSecond(now time.Time) float64 {
+	var total uint64
+	for i := int64(1); i <= rateWindow; i++ {
+		s := now.Unix() - i
+		v := c.slots[s%int64(len(c.slots))].Load()
+		if v>>32 == uint64(uint32(s)) {
+			total += v & 0xffffffff
+		}
+	}
+	return float64(total) / rateWindow
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errRateLimited is our own limit refusing a call, classed like a 429.
+var errRateLimited = fmt.Errorf("%w: local rate limit exceeded", ErrProcessorRateLimited)
+
+// rateLimiter is a token bucket shared by every outbound processor call, so
+// the worker as a whole stays under a processor's rate limit. Callers reserve
+// a token and sleep until it is due; a reservation that would wait longer
+// than maxWait is refused instead.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	maxWait time.Duration
+	tokens  float64
+	last    time.Time
+}
+
+func newRateLimiter(rate float64, burst int, maxWait time.Duration) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), maxWait: maxWait, tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a call may be made. It returns errRateLimited when the
+// wait would exceed maxWait, or ctx's error if ctx ends first.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	if delay > l.maxWait {
+		l.tokens++
+		l.mu.Unlock()
+		return errRateLimited
+	}
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Hand the reservation back so later callers are not delayed by it.
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
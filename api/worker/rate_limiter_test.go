@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate        float64
+		burst       int
+		maxWait     time.Duration
+		calls       int
+		wantRefused int
+		minElapsed  time.Duration // calls beyond the burst are spaced 1/rate apart
+	}{
+		{"burst passes at once", 10, 5, 0, 5, 0, 0},
+		{"beyond burst refused without wait", 10, 2, 0, 5, 3, 0},
+		{"beyond burst waits", 100, 1, time.Second, 6, 0, 50 * time.Millisecond},
+		{"wait longer than maxWait refused", 100, 1, 5 * time.Millisecond, 6, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(tt.rate, tt.burst, tt.maxWait)
+			start := time.Now()
+			refused := 0
+			for i := 0; i < tt.calls; i++ {
+				if err := l.wait(context.Background()); errors.Is(err, errRateLimited) {
+					refused++
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			elapsed := time.Since(start)
+			if refused != tt.wantRefused {
+				t.Errorf("refused %d calls, want %d", refused, tt.wantRefused)
+			}
+			if elapsed < tt.minElapsed {
+				t.Errorf("calls took %s, want at least %s", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+func TestRateLimiterCancelReturnsToken(t *testing.T) {
+	l := newRateLimiter(10, 1, time.Second)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait = %v, want DeadlineExceeded", err)
+	}
+	// Without the returned reservation the next call would wait about 200ms.
+	start := time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("wait after a cancelled reservation took %s", elapsed)
+	}
+}
+
+// TestCallProcessorRate checks that outbound calls through callProcessor stay
+// within the configured rate.
+func TestCallProcessorRate(t *testing.T) {
+	fake := newFakeProcessors(nil)
+	w := newTestWorker(fake)
+	const rate, calls = 200, 20
+	w.limiter = newRateLimiter(rate, 1, time.Second)
+	start := time.Now()
+	for i := 0; i < calls; i++ {
+		if err := w.callProcessor(context.Background(), "default", "http://default", payment("p1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	if got := float64(fake.callCount("default")-1) / elapsed.Seconds(); got > rate {
+		t.Errorf("outbound rate %.0f/s, want at most %d/s", got, rate)
+	}
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateCounter(t *testing.T) {
+	start := time.Unix(1751371200, 0) // a whole second
+	tests := []struct {
+		name   string
+		counts map[int]int // events per second offset from start
+		at     int         // second offset of the reading
+		want   float64
+	}{
+		{"steady", map[int]int{0: 5, 1: 5, 2: 5, 3: 5, 4: 5, 5: 5, 6: 5, 7: 5, 8: 5, 9: 5}, 10, 5},
+		{"second in progress not counted", map[int]int{9: 10, 10: 100}, 10, 1},
+		{"older than the window", map[int]int{0: 100, 5: 20}, 12, 2},
+		{"slot reused on a later lap", map[int]int{0: 100, 11: 30}, 12, 3},
+		{"idle", nil, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c rateCounter
+			for sec := 0; sec <= tt.at; sec++ {
+				for i := 0; i < tt.counts[sec]; i++ {
+					// Spread within the second; only the second matters.
+					c.add(start.Add(time.Duration(sec)*time.Second + time.Duration(i)*time.Millisecond))
+				}
+			}
+			if got := c.perSecond(start.Add(time.Duration(tt.at)*time.Second + 500*time.Millisecond)); got != tt.want {
+				t.Errorf("perSecond = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateCounterConcurrentAdds(t *testing.T) {
+	now := time.Unix(1751371200, 0)
+	var c rateCounter
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				c.add(now)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := c.perSecond(now.Add(time.Second)); got != 800 {
+		t.Errorf("perSecond = %v, want 800", got)
+	}
+}
@@ -0,0 +1,39 @@
+package worker
+
+import "sync"
+
+// retryBudget implements client-side adaptive throttling of retries: every
+// request earns ratio tokens and every retry spends one, so retries can never
+// exceed roughly ratio*requests. When a processor is degraded the budget runs
+// dry and we stop piling retries onto it.
+type retryBudget struct {
+	mu     sync.Mutex
+	ratio  float64
+	max    float64
+	tokens float64
+}
+
+func newRetryBudget(ratio, max float64) *retryBudget {
+	return &retryBudget{ratio: ratio, max: max, tokens: max}
+}
+
+// onRequest credits the budget for a first attempt.
+func (b *retryBudget) onRequest() {
+	b.mu.Lock()
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.mu.Unlock()
+}
+
+// tryRetry spends one token, reporting false when the budget is exhausted.
+func (b *retryBudget) tryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+func TestRetryBudget(t *testing.T) {
+	tests := []struct {
+		name        string
+		ratio, max  float64
+		requests    int
+		wantRetries int
+	}{
+		{"starts full", 0.1, 3, 0, 3},
+		{"requests earn tokens", 0.5, 2, 4, 2},
+		{"empty budget refills with requests", 0.5, 0, 4, 0},
+		{"tokens capped at max", 1, 2, 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newRetryBudget(tt.ratio, tt.max)
+			for i := 0; i < tt.requests; i++ {
+				b.onRequest()
+			}
+			retries := 0
+			for b.tryRetry() {
+				retries++
+			}
+			if retries != tt.wantRetries {
+				t.Errorf("retries = %d, want %d", retries, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestChargeWithRetriesStopsWhenBudgetSpent(t *testing.T) {
+	retries := config.ProcessorRetries
+	config.ProcessorRetries = 5
+	defer func() { config.ProcessorRetries = retries }()
+	unavailable := fmt.Errorf("%w: connection refused", ErrProcessorUnavailable)
+	fake := newFakeProcessors(map[string][]error{"default": {unavailable, unavailable, unavailable, unavailable, unavailable, unavailable}})
+	w := newTestWorker(fake)
+	// A single token and no credit per request: one retry, then none.
+	w.retryBudgets["default"] = newRetryBudget(0, 1)
+	err := w.chargeWithRetries(context.Background(), "default", "http://default", models.PaymentRequest{CorrelationID: "p1", Amount: "10"})
+	if !errors.Is(err, ErrProcessorUnavailable) {
+		t.Fatalf("chargeWithRetries = %v, want unavailable", err)
+	}
+	if n := fake.callCount("default"); n != 2 {
+		t.Errorf("processor calls = %d, want 2 (one retry)", n)
+	}
+}
+
+func TestChargeWithRetriesDefault(t *testing.T) {
+	t.Setenv("PROCESSOR_RETRIES", "")
+	config.Init()
+	timeout := fmt.Errorf("%w: deadline exceeded", ErrProcessorTimeout)
+	fake := newFakeProcessors(map[string][]error{"default": {timeout}})
+	w := newTestWorker(fake)
+	if err := w.chargeWithRetries(context.Background(), "default", "http://default", models.PaymentRequest{CorrelationID: "p1", Amount: "10"}); !errors.Is(err, ErrProcessorTimeout) {
+		t.Fatalf("chargeWithRetries = %v, want a timeout", err)
+	}
+	if n := fake.callCount("default"); n != 1 {
+		t.Errorf("processor calls = %d, want 1: a timed-out POST is not re-sent by default", n)
+	}
+}
+
+func TestChargeWithRetriesBacksOff(t *testing.T) {
+	retries := config.ProcessorRetries
+	config.ProcessorRetries = 1
+	defer func() { config.ProcessorRetries = retries }()
+	backoff.SetJitter(backoff.JitterNone)
+	defer backoff.SetJitter(backoff.JitterFull)
+	unavailable := fmt.Errorf("%w: connection refused", ErrProcessorUnavailable)
+
+	fake := newFakeProcessors(map[string][]error{"default": {unavailable}})
+	w := newTestWorker(fake)
+	start := time.Now()
+	if err := w.chargeWithRetries(context.Background(), "default", "http://default", payment("p1")); err != nil {
+		t.Fatalf("chargeWithRetries = %v, want the retry to succeed", err)
+	}
+	if want := backoff.Delay(1, processorRetryBase, processorRetryMax); time.Since(start) < want {
+		t.Errorf("retried after %s, want a backoff of %s", time.Since(start), want)
+	}
+
+	// A deadline that ends during the backoff ends the retries without
+	// waiting for it.
+	fake = newFakeProcessors(map[string][]error{"default": {unavailable, unavailable}})
+	w = newTestWorker(fake)
+	ctx, cancel := context.WithTimeout(context.Background(), processorRetryBase/2)
+	defer cancel()
+	if err := w.chargeWithRetries(ctx, "default", "http://default", payment("p2")); !errors.Is(err, ErrProcessorUnavailable) {
+		t.Fatalf("chargeWithRetries = %v, want unavailable", err)
+	}
+	if n := fake.callCount("default"); n != 1 {
+		t.Errorf("processor calls = %d, want 1", n)
+	}
+}
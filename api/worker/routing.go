@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+type processorTarget struct {
+	name, url string
+}
+
+// selectProcessors returns the healthy processors in the order to try them.
+// The default processor charges the lower fee, so it normally goes first.
+// With config.CheapRoutingThreshold set, payments below it may start on the
+// fallback when its last reported minResponseTime is lower: on small amounts
+// the fee difference matters less than latency. Otherwise, with fee
+// schedules for both processors, the one charging the payment less goes
+// first. With config.ProcessorWeights set, a weighted round-robin picks the
+// first processor instead of all of the above. Ahead of all of these, a
+// processor marked degraded by the health poll goes after one that is not.
+// The first choice is counted in w.routed.
+func (w *Worker) selectProcessors(req models.PaymentRequest, defaultHealthy, fallbackHealthy bool) []processorTarget {
+	targets := w.orderProcessors(req, defaultHealthy, fallbackHealthy)
+	if len(targets) > 0 {
+		w.routed.add(targets[0].name)
+	}
+	return targets
+}
+
+func (w *Worker) orderProcessors(req models.PaymentRequest, defaultHealthy, fallbackHealthy bool) []processorTarget {
+	def := processorTarget{"default", config.ProcessorURL("default")}
+	fb := processorTarget{"fallback", config.ProcessorURL("fallback")}
+	switch {
+	case defaultHealthy && fallbackHealthy:
+		if defDegraded, fbDegraded := w.defaultDegraded.Load(), w.fallbackDegraded.Load(); defDegraded != fbDegraded {
+			if defDegraded {
+				return []processorTarget{fb, def}
+			}
+			return []processorTarget{def, fb}
+		}
+		if config.ProcessorWeights != nil {
+			if w.weighted.next() == "fallback" {
+				return []processorTarget{fb, def}
+			}
+			return []processorTarget{def, fb}
+		}
+		if config.CheapRoutingThreshold > 0 && req.Amount.Float64() < config.CheapRoutingThreshold &&
+			w.fallbackLatency.Load() < w.defaultLatency.Load() {
+			return []processorTarget{fb, def}
+		}
+		if fallbackCheaper(req.Amount.Float64()) {
+			return []processorTarget{fb, def}
+		}
+		return []processorTarget{def, fb}
+	case defaultHealthy:
+		return []processorTarget{def}
+	case fallbackHealthy:
+		return []processorTarget{fb}
+	}
+	return nil
+}
+
+// fallbackCheaper reports whether the fee schedules make the fallback strictly
+// cheaper than the default for amount. Ties and missing schedules keep the
+// default first.
+func fallbackCheaper(amount float64) bool {
+	defFee, ok := config.ProcessorFee("default", amount)
+	if !ok {
+		return false
+	}
+	fbFee, ok := config.ProcessorFee("fallback", amount)
+	return ok && fbFee < defFee
+}
+
+// routingCounts counts, per processor, the payments routed to it first.
+type routingCounts struct {
+	def, fallback atomic.Int64
+}
+
+func (c *routingCounts) add(name string) {
+	if name == "default" {
+		c.def.Add(1)
+	} else {
+		c.fallback.Add(1)
+	}
+}
+
+func (c *routingCounts) snapshot() map[string]int64 {
+	return map[string]int64{"default": c.def.Load(), "fallback": c.fallback.Load()}
+}
+
+// weightedRoundRobin spreads picks between the processors in proportion to
+// config.ProcessorWeights, using nginx's smooth weighted round-robin so the
+// picks of each processor are interleaved rather than bunched: with 80/20,
+// every run of five picks holds exactly one fallback.
+type weightedRoundRobin struct {
+	mu                    sync.Mutex
+	defCurrent, fbCurrent int
+}
+
+func (r *weightedRoundRobin) next() string {
+	defWeight, fbWeight := config.ProcessorWeights["default"], config.ProcessorWeights["fallback"]
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defCurrent += defWeight
+	r.fbCurrent += fbWeight
+	if r.fbCurrent > r.defCurrent {
+		r.fbCurrent -= defWeight + fbWeight
+		return "fallback"
+	}
+	r.defCurrent -= defWeight + fbWeight
+	return "default"
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+// TestProcessPaymentRouting drives processPayment through the fake
+// ProcessorClient. An empty bloom filter answers the duplicate check and a
+// fake batcher records the outcome, so no database is needed.
+func TestProcessPaymentRouting(t *testing.T) {
+	setBatcherConfig(t)
+	defer func(retries int) { config.ProcessorRetries = retries }(config.ProcessorRetries)
+	config.ProcessorRetries = 0
+	unavailable := fmt.Errorf("%w: connection refused", ErrProcessorUnavailable)
+
+	tests := []struct {
+		name              string
+		defaultHealthy    bool
+		fallbackHealthy   bool
+		defaultDegraded   bool
+		script            map[string][]error
+		wantProcessor     string
+		wantDefaultCalls  int
+		wantFallbackCalls int
+	}{
+		{"default first", true, true, false, nil, "default", 1, 0},
+		{"fallback after default fails", true, true, false, map[string][]error{"default": {unavailable}}, "fallback", 1, 1},
+		{"unhealthy default skipped", false, true, false, nil, "fallback", 0, 1},
+		{"degraded default goes second", true, true, true, nil, "fallback", 0, 1},
+		{"only default healthy", true, false, false, nil, "default", 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeProcessors(tt.script)
+			outcomes := newFakeOutcomes()
+			w := newTestWorker(fake)
+			w.seen = newBloomFilter(1024, 3)
+			w.batcher = outcomes.batcher()
+			w.dbHealthy.Store(true)
+			w.defaultHealthy.Store(tt.defaultHealthy)
+			w.fallbackHealthy.Store(tt.fallbackHealthy)
+			w.defaultDegraded.Store(tt.defaultDegraded)
+
+			w.processPayment(payment("p1"))
+			w.batcher.close()
+
+			if got := outcomes.processor["p1"]; got != tt.wantProcessor {
+				t.Errorf("recorded with %q, want %q", got, tt.wantProcessor)
+			}
+			if got := fake.callCount("default"); got != tt.wantDefaultCalls {
+				t.Errorf("default calls = %d, want %d", got, tt.wantDefaultCalls)
+			}
+			if got := fake.callCount("fallback"); got != tt.wantFallbackCalls {
+				t.Errorf("fallback calls = %d, want %d", got, tt.wantFallbackCalls)
+			}
+		})
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	defer func(weights map[string]int) { config.ProcessorWeights = weights }(config.ProcessorWeights)
+
+	tests := []struct {
+		name    string
+		def, fb int
+		want    string // d for default, f for fallback, one per pick
+	}{
+		{"80/20 interleaved", 80, 20, "ddfdd" + "ddfdd"},
+		{"2/1", 2, 1, "dfd" + "dfd"},
+		{"even", 1, 1, "dfdf"},
+		{"zero fallback", 100, 0, "dddd"},
+		{"zero default", 0, 100, "ffff"},
+		{"both zero", 0, 0, "dddd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.ProcessorWeights = map[string]int{"default": tt.def, "fallback": tt.fb}
+			var r weightedRoundRobin
+			got := make([]byte, len(tt.want))
+			for i := range got {
+				got[i] = r.next()[0]
+			}
+			if string(got) != tt.want {
+				t.Errorf("picks = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFeeOrdering checks that with fee schedules for both processors the one
+// charging the payment less goes first, and that a tie keeps the default.
+func TestFeeOrdering(t *testing.T) {
+	defer func(s map[string][]config.FeeTier) { config.ProcessorFeeSchedules = s }(config.ProcessorFeeSchedules)
+	config.ProcessorFeeSchedules = map[string][]config.FeeTier{
+		"default":  {{Percent: 5}, {MinAmount: 1000, Percent: 5, Fixed: 50}},
+		"fallback": {{Percent: 15}, {MinAmount: 1000, Percent: 4}},
+	}
+
+	tests := []struct {
+		amount models.Amount
+		want   string
+	}{
+		{"10.00", "default"},
+		{"1000.00", "fallback"}, // 100.00 against 40.00
+		{"5000.00", "fallback"},
+	}
+	w := newTestWorker(nil)
+	for _, tt := range tests {
+		req := payment("p1")
+		req.Amount = tt.amount
+		if got := w.orderProcessors(req, true, true); got[0].name != tt.want {
+			t.Errorf("amount %s: %s first, want %s", tt.amount, got[0].name, tt.want)
+		}
+	}
+
+	config.ProcessorFeeSchedules["fallback"] = []config.FeeTier{{Percent: 5}}
+	if got := w.orderProcessors(payment("p1"), true, true); got[0].name != "default" {
+		t.Errorf("equal fees: %s first, want default", got[0].name)
+	}
+	delete(config.ProcessorFeeSchedules, "fallback")
+	req := payment("p1")
+	req.Amount = "5000.00"
+	if got := w.orderProcessors(req, true, true); got[0].name != "default" {
+		t.Errorf("no fallback schedule: %s first, want default", got[0].name)
+	}
+}
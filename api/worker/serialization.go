@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"rinha-backend-golang/backoff"
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// serializationFailure is the SQLSTATE of a statement that lost a
+// serialization conflict under REPEATABLE READ or SERIALIZABLE isolation,
+// e.g. with default_transaction_isolation raised on the server. Run again,
+// it normally succeeds.
+const serializationFailure = "40001"
+
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == serializationFailure
+}
+
+// retrySerializable runs fn, and again up to config.SerializationRetries
+// times while it fails with a serialization failure and ctx allows.
+func retrySerializable(ctx context.Context, what string, fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt <= config.SerializationRetries && isSerializationFailure(err); attempt++ {
+		logging.Warnf("Worker: %s hit a serialization failure, retrying (attempt %d)", what, attempt)
+		select {
+		case <-time.After(backoff.Delay(attempt, 5*time.Millisecond, 100*time.Millisecond)):
+		case <-ctx.Done():
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
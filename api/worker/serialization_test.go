@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"rinha-backend-golang/config"
+)
+
+func TestRetrySerializable(t *testing.T) {
+	defer func(retries int) { config.SerializationRetries = retries }(config.SerializationRetries)
+	config.SerializationRetries = 3
+	serialization := &pgconn.PgError{Code: serializationFailure}
+	wrapped := fmt.Errorf("duplicate check: %w", serialization)
+	other := &pgconn.PgError{Code: "23505"}
+
+	tests := []struct {
+		name      string
+		script    []error // one per call; calls past the end succeed
+		wantCalls int
+		wantErr   error
+	}{
+		{"success first time", nil, 1, nil},
+		{"serialization failure then success", []error{serialization}, 2, nil},
+		{"wrapped serialization failure retried", []error{wrapped, wrapped}, 3, nil},
+		{"other error not retried", []error{other}, 1, other},
+		{"gives up after the retries", []error{serialization, serialization, serialization, serialization, serialization}, 4, serialization},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retrySerializable(context.Background(), "test", func() error {
+				calls++
+				if calls <= len(tt.script) {
+					return tt.script[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetrySerializableStopsOnCancel(t *testing.T) {
+	defer func(retries int) { config.SerializationRetries = retries }(config.SerializationRetries)
+	config.SerializationRetries = 3
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := retrySerializable(ctx, "test", func() error {
+		calls++
+		return &pgconn.PgError{Code: serializationFailure}
+	})
+	if calls != 1 || !isSerializationFailure(err) {
+		t.Errorf("calls = %d, err = %v; want 1 call returning the serialization failure", calls, err)
+	}
+}
@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// paymentSet tracks payments by correlation ID.
+type paymentSet struct {
+	mu sync.Mutex
+	m  map[string]models.PaymentRequest
+}
+
+func newPaymentSet() *paymentSet {
+	return &paymentSet{m: make(map[string]models.PaymentRequest)}
+}
+
+func (s *paymentSet) add(req models.PaymentRequest) {
+	s.mu.Lock()
+	s.m[req.CorrelationID] = req
+	s.mu.Unlock()
+}
+
+func (s *paymentSet) remove(correlationID string) {
+	s.mu.Lock()
+	delete(s.m, correlationID)
+	s.mu.Unlock()
+}
+
+func (s *paymentSet) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.m)
+}
+
+func (s *paymentSet) list() []models.PaymentRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs := make([]models.PaymentRequest, 0, len(s.m))
+	for _, req := range s.m {
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+// Serve runs srv until SIGTERM or SIGINT, then shuts down gracefully.
+func (w *Worker) Serve(srv *http.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	w.shutdown(srv)
+}
+
+// shutdown stops serving HTTP and drains the worker.
+func (w *Worker) shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logging.Warnf("Worker: HTTP shutdown: %v", err)
+	}
+	w.Drain()
+}
+
+// Drain stops taking new payments, gives in-flight ones until
+// config.ShutdownGrace to finish and saves whatever is left, including
+// payments waiting for a re-process pass, to payment_outbox.
+func (w *Worker) Drain() {
+	logging.Infof("Worker: draining, %d payments in flight, grace period %s", w.active.len(), config.ShutdownGrace)
+	w.stopping.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownGrace)
+	defer cancel()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for w.active.len() > 0 && ctx.Err() == nil {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+		}
+	}
+	if w.batcher != nil {
+		w.batcher.close()
+	}
+	w.saveUnfinished()
+}
+
+// saveUnfinished writes every tracked payment to payment_outbox. A payment
+// may still complete after being saved; the duplicate check skips it when
+// the outbox is drained.
+func (w *Worker) saveUnfinished() {
+	reqs := append(w.active.list(), w.scheduled.list()...)
+	if len(reqs) == 0 {
+		return
+	}
+	if w.db == nil {
+		logging.Errorf("Worker: losing %d unfinished payments, no database to save them to", len(reqs))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	saved := 0
+	for _, req := range reqs {
+		if _, err := w.db.Exec(ctx, `INSERT INTO payment_outbox (correlation_id, amount, attempts)
+            VALUES ($1,$2,$3) ON CONFLICT (correlation_id) DO NOTHING`,
+			req.CorrelationID, req.Amount, req.Attempts); err != nil {
+			logging.Errorf("Worker: Error saving unfinished payment %s: %v", req.CorrelationID, err)
+			continue
+		}
+		saved++
+	}
+	logging.Infof("Worker: saved %d/%d unfinished payments to payment_outbox", saved, len(reqs))
+}
+
+// drainOutbox picks up the payments a previous worker saved on shutdown.
+func (w *Worker) drainOutbox() {
+	rows, err := w.db.Query(context.Background(), `DELETE FROM payment_outbox
+        RETURNING correlation_id, amount::text, attempts`)
+	if err != nil {
+		logging.Errorf("Worker: could not drain payment_outbox: %v", err)
+		return
+	}
+	var reqs []models.PaymentRequest
+	for rows.Next() {
+		var req models.PaymentRequest
+		if err := rows.Scan(&req.CorrelationID, &req.Amount, &req.Attempts); err != nil {
+			logging.Errorf("Worker: payment_outbox scan error: %v", err)
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	rows.Close()
+	if len(reqs) > 0 {
+		logging.Infof("Worker: resuming %d payments from payment_outbox", len(reqs))
+	}
+	for _, req := range reqs {
+		w.scheduled.add(req)
+		go w.reprocess(req)
+	}
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+)
+
+// TestDrainSavesUnfinished checks that payments still in flight when the
+// grace period ends, and those waiting for a re-process pass, land in
+// payment_outbox.
+func TestDrainSavesUnfinished(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, "TRUNCATE payment_outbox"); err != nil {
+		t.Fatal(err)
+	}
+	defer func(grace time.Duration) { config.ShutdownGrace = grace }(config.ShutdownGrace)
+	config.ShutdownGrace = 50 * time.Millisecond
+
+	w := newTestWorker(nil)
+	w.db = pool
+	w.active.add(payment("in-flight"))
+	retry := payment("scheduled")
+	retry.Attempts = 2
+	w.scheduled.add(retry)
+
+	start := time.Now()
+	w.Drain()
+	if elapsed := time.Since(start); elapsed < config.ShutdownGrace {
+		t.Errorf("Drain returned after %s, before the grace period", elapsed)
+	}
+	if !w.stopping.Load() {
+		t.Error("worker still takes payments after Drain")
+	}
+
+	want := map[string]int{"in-flight": 0, "scheduled": 2}
+	rows, err := pool.Query(ctx, "SELECT correlation_id, attempts FROM payment_outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := map[string]int{}
+	for rows.Next() {
+		var id string
+		var attempts int
+		if err := rows.Scan(&id, &attempts); err != nil {
+			t.Fatal(err)
+		}
+		got[id] = attempts
+	}
+	if len(got) != len(want) {
+		t.Fatalf("payment_outbox holds %v, want %v", got, want)
+	}
+	for id, attempts := range want {
+		if a, ok := got[id]; !ok || a != attempts {
+			t.Errorf("%s: saved %t with %d attempts, want %d", id, ok, a, attempts)
+		}
+	}
+}
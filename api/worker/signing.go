@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+
+	"rinha-backend-golang/config"
+)
+
+// signatureHeader carries the hex HMAC of the request body for processors
+// configured with a secret.
+const signatureHeader = "X-Signature"
+
+// signBody returns the hex-encoded HMAC of body under the named processor's
+// secret and algorithm, or "" when the processor has no secret.
+func signBody(name string, body []byte) string {
+	secret := config.ProcessorHMACSecrets[name]
+	if secret == "" {
+		return ""
+	}
+	var newHash func() hash.Hash
+	switch config.ProcessorHMACAlgos[name] {
+	case "sha1":
+		newHash = sha1.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		newHash = sha256.New
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"testing"
+
+	"rinha-backend-golang/config"
+)
+
+// TestSignBody checks signBody against the published HMAC vectors for key
+// "Jefe" (RFC 2202 and RFC 4231, test case 2).
+func TestSignBody(t *testing.T) {
+	defer func(secrets, algos map[string]string) {
+		config.ProcessorHMACSecrets, config.ProcessorHMACAlgos = secrets, algos
+	}(config.ProcessorHMACSecrets, config.ProcessorHMACAlgos)
+	body := []byte("what do ya want for nothing?")
+
+	tests := []struct {
+		algo, secret, want string
+	}{
+		{"sha1", "Jefe", "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79"},
+		{"sha256", "Jefe", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"", "Jefe", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{"sha512", "Jefe", "164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea2505549758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737"},
+		{"sha256", "", ""},
+	}
+	for _, tt := range tests {
+		config.ProcessorHMACSecrets = map[string]string{"default": tt.secret}
+		config.ProcessorHMACAlgos = map[string]string{"default": tt.algo}
+		if got := signBody("default", body); got != tt.want {
+			t.Errorf("algo %q, secret %q: signature %s, want %s", tt.algo, tt.secret, got, tt.want)
+		}
+	}
+}
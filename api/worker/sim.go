@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"net/http"
+
+	"rinha-backend-golang/logging"
+)
+
+// Forced health states set through /sim/processor.
+const (
+	healthNotForced int32 = iota
+	healthForcedUp
+	healthForcedDown
+)
+
+// handleSimProcessor overrides the worker's view of one processor's health so
+// failover can be driven deterministically in tests:
+// POST /sim/processor?name=default&down=true|false|auto, where auto hands
+// control back to the health checks. It is only registered with
+// SIM_ENDPOINTS=true.
+func (w *Worker) handleSimProcessor(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name != "default" && name != "fallback" {
+		http.Error(wr, "name must be default or fallback", http.StatusBadRequest)
+		return
+	}
+	var state int32
+	switch r.URL.Query().Get("down") {
+	case "true":
+		state = healthForcedDown
+	case "false":
+		state = healthForcedUp
+	case "auto":
+		state = healthNotForced
+	default:
+		http.Error(wr, "down must be true, false or auto", http.StatusBadRequest)
+		return
+	}
+	if name == "default" {
+		w.defaultForced.Store(state)
+	} else {
+		w.fallbackForced.Store(state)
+	}
+	// Drop the cached reading so the override applies to the next payment.
+	w.healthCache.Store(nil)
+	logging.Warnf("Worker: simulated health for %s processor set to down=%s", name, r.URL.Query().Get("down"))
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+// applyForced replaces a polled health value with a simulated one, if set.
+func applyForced(healthy bool, forced int32) bool {
+	switch forced {
+	case healthForcedUp:
+		return true
+	case healthForcedDown:
+		return false
+	}
+	return healthy
+}
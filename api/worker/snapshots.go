@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// startSummarySnapshots records the current summary every
+// config.SummarySnapshotInterval, building a cheap time series.
+func (w *Worker) startSummarySnapshots() {
+	ticker := time.NewTicker(config.SummarySnapshotInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := w.takeSummarySnapshot(context.Background()); err != nil {
+			logging.Errorf("Worker: summary snapshot error: %v", err)
+		}
+	}
+}
+
+func (w *Worker) takeSummarySnapshot(ctx context.Context) error {
+	summary, err := querySummary(ctx, w.db, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = w.db.Exec(ctx, `INSERT INTO summary_snapshots
+        (default_requests, default_amount, fallback_requests, fallback_amount) VALUES ($1,$2,$3,$4)
+        ON CONFLICT (taken_at) DO NOTHING`,
+		summary.Default.TotalRequests, summary.Default.TotalAmount,
+		summary.Fallback.TotalRequests, summary.Fallback.TotalAmount)
+	return err
+}
+
+func (w *Worker) handleSnapshots(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := w.readDB.Query(context.Background(), `SELECT taken_at, default_requests, default_amount, fallback_requests, fallback_amount
+        FROM summary_snapshots WHERE `+rangeFilterOn("taken_at")+` ORDER BY taken_at`, from, to)
+	if err != nil {
+		logging.Errorf("Worker: snapshots query error: %v", err)
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	snapshots := make([]models.SummarySnapshot, 0)
+	for rows.Next() {
+		var s models.SummarySnapshot
+		if err := rows.Scan(&s.TakenAt, &s.Default.TotalRequests, &s.Default.TotalAmount,
+			&s.Fallback.TotalRequests, &s.Fallback.TotalAmount); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(snapshots)
+}
@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// eventHub fans processed-payment events out to /payments/stream clients.
+// Each subscriber has its own buffered channel; publishing never blocks, and
+// a subscriber whose buffer is full is evicted rather than slowing payments.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan models.PaymentEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan models.PaymentEvent]struct{})}
+}
+
+func (h *eventHub) subscribe() chan models.PaymentEvent {
+	ch := make(chan models.PaymentEvent, config.StreamBufferSize)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+// unsubscribe removes ch unless publish already evicted it.
+func (h *eventHub) unsubscribe(ch chan models.PaymentEvent) {
+	h.mu.Lock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+	h.mu.Unlock()
+}
+
+func (h *eventHub) publish(ev models.PaymentEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			logging.Warnf("Worker: evicting slow payment stream subscriber")
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// handlePaymentStream streams every payment processed from now on as
+// Server-Sent Events. The stream ends when the client disconnects or, if it
+// falls config.StreamBufferSize events behind, when it is evicted.
+func (w *Worker) handlePaymentStream(wr http.ResponseWriter, r *http.Request) {
+	flusher, ok := wr.(http.Flusher)
+	if !ok {
+		http.Error(wr, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	wr.Header().Set("Content-Type", "text/event-stream")
+	wr.Header().Set("Cache-Control", "no-cache")
+	wr.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := w.events.subscribe()
+	defer w.events.unsubscribe(events)
+	// Comments keep idle proxies from closing the connection.
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(wr, ": keepalive\n\n")
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(wr, "event: payment\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
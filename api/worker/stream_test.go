@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+func TestEventHub(t *testing.T) {
+	defer func(size int) { config.StreamBufferSize = size }(config.StreamBufferSize)
+	config.StreamBufferSize = 3
+	h := newEventHub()
+	fast := h.subscribe()
+	slow := h.subscribe()
+
+	// fast keeps up, slow never reads and falls behind on the fourth event.
+	for i := 0; i < 4; i++ {
+		h.publish(models.PaymentEvent{CorrelationID: fmt.Sprintf("p%d", i)})
+		if ev := <-fast; ev.CorrelationID != fmt.Sprintf("p%d", i) {
+			t.Errorf("fast subscriber got %s, want p%d", ev.CorrelationID, i)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if ev, ok := <-slow; !ok || ev.CorrelationID != fmt.Sprintf("p%d", i) {
+			t.Errorf("slow subscriber got %s, %t; want its buffered p%d", ev.CorrelationID, ok, i)
+		}
+	}
+	if _, ok := <-slow; ok {
+		t.Error("slow subscriber was not evicted")
+	}
+
+	h.publish(models.PaymentEvent{CorrelationID: "p4"})
+	if ev := <-fast; ev.CorrelationID != "p4" {
+		t.Errorf("fast subscriber got %s after the eviction, want p4", ev.CorrelationID)
+	}
+	// Unsubscribing after an eviction must not close the channel twice.
+	h.unsubscribe(slow)
+	h.unsubscribe(fast)
+	if _, ok := <-fast; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+}
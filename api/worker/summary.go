@@ -0,0 +1,208 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+func (w *Worker) handlePaymentsSummary(wr http.ResponseWriter, r *http.Request) {
+	if !w.dbHealthy.Load() {
+		http.Error(wr, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	// Amounts are stored in major units; cents only changes how they are reported.
+	var factor float64
+	switch unit := r.URL.Query().Get("unit"); unit {
+	case "", "major":
+		factor = 1
+	case "cents":
+		factor = 100
+	default:
+		http.Error(wr, "unit must be major or cents", http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := summaryContext()
+	defer cancel()
+	// One REPEATABLE READ snapshot for every query below, so the per-processor
+	// totals and the status breakdown describe the same set of rows even while
+	// payments are being inserted.
+	tx, err := w.readDB.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		summaryQueryFailed(ctx, wr, "summary", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+	summary, err := querySummary(ctx, tx, from, to)
+	if err != nil {
+		summaryQueryFailed(ctx, wr, "summary", err)
+		return
+	}
+	if r.URL.Query().Get("byStatus") == "true" {
+		if summary.ByStatus, err = queryStatusBreakdown(ctx, tx, from, to); err != nil {
+			summaryQueryFailed(ctx, wr, "status breakdown", err)
+			return
+		}
+	}
+	if r.URL.Query().Get("detailed") == "true" {
+		if summary.AmountStats, err = queryAmountStats(ctx, tx, from, to); err != nil {
+			summaryQueryFailed(ctx, wr, "amount stats", err)
+			return
+		}
+	}
+	if r.URL.Query().Get("rate") == "true" {
+		rate := w.rate.perSecond(time.Now())
+		summary.RatePerSecond = &rate
+	}
+	if config.ProcessorFeeSchedules != nil || config.ProcessorWeights != nil {
+		summary.Routing = w.routed.snapshot()
+	}
+	if factor != 1 {
+		scaleSummary(&summary, factor)
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(summary)
+}
+
+// scaleSummary multiplies every total amount in resp by factor.
+func scaleSummary(resp *models.PaymentSummaryResponse, factor float64) {
+	scale := func(s *models.Summary) {
+		// Round so cents come out whole despite float representation.
+		s.TotalAmount = math.Round(s.TotalAmount*factor*100) / 100
+	}
+	scale(&resp.Default)
+	scale(&resp.Fallback)
+	if resp.DryRun != nil {
+		scale(resp.DryRun)
+	}
+	if resp.Other != nil {
+		scale(resp.Other)
+	}
+	for status, s := range resp.ByStatus {
+		scale(&s)
+		resp.ByStatus[status] = s
+	}
+	for proc, s := range resp.AmountStats {
+		s.Min, s.Max, s.Avg = s.Min*factor, s.Max*factor, s.Avg*factor
+		resp.AmountStats[proc] = s
+	}
+}
+
+// querier is what the summary queries need from a pool or a transaction.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// QuerySummary aggregates the processed payments in db per processor, for
+// callers outside the worker such as the gateway's gRPC GetSummary.
+func QuerySummary(ctx context.Context, db *pgxpool.Pool) (models.PaymentSummaryResponse, error) {
+	return querySummary(ctx, db, nil, nil)
+}
+
+// querySummary aggregates the processed payments created within from/to (nil
+// for an open side) per processor. Without a range it includes those already
+// pruned by PAYMENT_RETENTION_S; their totals carry no timestamps, so a
+// ranged summary cannot place them and leaves them out.
+func querySummary(ctx context.Context, q querier, from, to *time.Time) (models.PaymentSummaryResponse, error) {
+	var summary models.PaymentSummaryResponse
+	rows, err := q.Query(ctx, `SELECT processor, SUM(cnt)::bigint, SUM(amt) FROM (
+            SELECT processor, COUNT(*) AS cnt, COALESCE(SUM(amount),0) AS amt FROM payments
+            WHERE status = 'processed' AND `+rangeFilter+` GROUP BY processor
+            UNION ALL
+            SELECT NULLIF(processor, ''), total_requests, total_amount FROM payments_pruned_totals
+            WHERE $1::timestamptz IS NULL AND $2::timestamptz IS NULL
+        ) t GROUP BY processor`, from, to)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	var dryRunSummary, otherSummary models.Summary
+	for rows.Next() {
+		var proc *string
+		var cnt int64
+		var amt float64
+		if err := rows.Scan(&proc, &cnt, &amt); err != nil {
+			continue
+		}
+		switch {
+		case proc != nil && *proc == "default":
+			summary.Default = models.Summary{TotalRequests: cnt, TotalAmount: amt}
+		case proc != nil && *proc == "fallback":
+			summary.Fallback = models.Summary{TotalRequests: cnt, TotalAmount: amt}
+		case proc != nil && *proc == "dry-run":
+			dryRunSummary = models.Summary{TotalRequests: cnt, TotalAmount: amt}
+		default:
+			// Unknown or NULL processors are folded together so the totals
+			// always reconcile with the row count.
+			otherSummary.TotalRequests += cnt
+			otherSummary.TotalAmount += amt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+
+	if dryRunSummary.TotalRequests > 0 {
+		summary.DryRun = &dryRunSummary
+	}
+	if otherSummary.TotalRequests > 0 {
+		summary.Other = &otherSummary
+	}
+	return summary, nil
+}
+
+// queryStatusBreakdown aggregates every payment row created within from/to
+// per processing status.
+func queryStatusBreakdown(ctx context.Context, q querier, from, to *time.Time) (map[string]models.Summary, error) {
+	rows, err := q.Query(ctx, "SELECT status, COUNT(*), COALESCE(SUM(amount),0) FROM payments WHERE "+rangeFilter+" GROUP BY status", from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	breakdown := make(map[string]models.Summary)
+	for rows.Next() {
+		var status string
+		var s models.Summary
+		if err := rows.Scan(&status, &s.TotalRequests, &s.TotalAmount); err != nil {
+			continue
+		}
+		breakdown[status] = s
+	}
+	return breakdown, rows.Err()
+}
+
+// queryAmountStats computes the amount statistics of the processed payments
+// created within from/to per processor, NULL processors reported as "other".
+func queryAmountStats(ctx context.Context, q querier, from, to *time.Time) (map[string]models.AmountStats, error) {
+	rows, err := q.Query(ctx, `SELECT COALESCE(processor, 'other'), MIN(amount)::float8, MAX(amount)::float8, AVG(amount)::float8
+        FROM payments WHERE status = 'processed' AND `+rangeFilter+` GROUP BY 1`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := make(map[string]models.AmountStats)
+	for rows.Next() {
+		var proc string
+		var s models.AmountStats
+		if err := rows.Scan(&proc, &s.Min, &s.Max, &s.Avg); err != nil {
+			continue
+		}
+		stats[proc] = s
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,153 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/models"
+)
+
+func TestPaymentsSummaryRange(t *testing.T) {
+	defer func(max time.Duration) { config.SummaryMaxRange = max }(config.SummaryMaxRange)
+	config.SummaryMaxRange = 7 * 24 * time.Hour
+	w := newTestWorker(nil)
+	w.dbHealthy.Store(true)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"over the limit", "from=2025-01-01T00:00:00Z&to=2025-01-09T00:00:00Z"},
+		{"to before from", "from=2025-01-02T00:00:00Z&to=2025-01-01T00:00:00Z"},
+		{"malformed from", "from=yesterday"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w.handlePaymentsSummary(rec, httptest.NewRequest(http.MethodGet, "/payments-summary?"+tt.query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestPaymentsSummaryQueryTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { config.SummaryQueryTimeout = timeout }(config.SummaryQueryTimeout)
+	config.SummaryQueryTimeout = 50 * time.Millisecond
+	w := newTestWorker(nil)
+	w.dbHealthy.Store(true)
+	w.readDB = stallingPool(t)
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	w.handlePaymentsSummary(rec, httptest.NewRequest(http.MethodGet, "/payments-summary", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 10*config.SummaryQueryTimeout {
+		t.Errorf("summary took %s against a stalled database", elapsed)
+	}
+}
+
+func TestPaymentsSummaryRangeDB(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	day := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	rows := []struct {
+		id, processor string
+		amount        string
+		at            time.Time
+	}{
+		{"a", "default", "10", day.Add(-time.Hour)},
+		{"b", "default", "20", day.Add(time.Hour)},
+		{"c", "fallback", "5", day.Add(2 * time.Hour)},
+		{"d", "default", "40", day.Add(25 * time.Hour)},
+	}
+	for _, r := range rows {
+		if _, err := pool.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor, status, created_at) VALUES ($1,$2,$3,'processed',$4)",
+			r.id, r.amount, r.processor, r.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pool.Exec(ctx, "INSERT INTO payments_pruned_totals (processor, total_requests, total_amount) VALUES ('default', 3, 30)"); err != nil {
+		t.Fatal(err)
+	}
+	w := newTestWorker(nil)
+	w.dbHealthy.Store(true)
+	w.readDB = pool
+
+	tests := []struct {
+		name         string
+		query        string
+		wantDefault  models.Summary
+		wantFallback models.Summary
+	}{
+		{"no range includes pruned", "", models.Summary{TotalRequests: 6, TotalAmount: 100}, models.Summary{TotalRequests: 1, TotalAmount: 5}},
+		{"one day", "from=2025-07-01T00:00:00Z&to=2025-07-01T23:59:59Z", models.Summary{TotalRequests: 1, TotalAmount: 20}, models.Summary{TotalRequests: 1, TotalAmount: 5}},
+		{"open end", "from=2025-07-01T12:00:00Z", models.Summary{TotalRequests: 1, TotalAmount: 40}, models.Summary{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w.handlePaymentsSummary(rec, httptest.NewRequest(http.MethodGet, "/payments-summary?"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			var got models.PaymentSummaryResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Default != tt.wantDefault || got.Fallback != tt.wantFallback {
+				t.Errorf("default %+v, fallback %+v; want %+v, %+v", got.Default, got.Fallback, tt.wantDefault, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestQueryAmountStats(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	rows := []struct {
+		id, amount, status string
+		processor          *string
+	}{
+		{"a", "10.00", "processed", ptr("default")},
+		{"b", "20.00", "processed", ptr("default")},
+		{"c", "60.00", "processed", ptr("default")},
+		{"d", "5.50", "processed", ptr("fallback")},
+		{"e", "1000.00", "failed", ptr("default")},
+		{"f", "7.00", "processed", nil},
+	}
+	for _, r := range rows {
+		if _, err := pool.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor, status) VALUES ($1,$2,$3,$4)",
+			r.id, r.amount, r.processor, r.status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := queryAmountStats(ctx, pool, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]models.AmountStats{
+		"default":  {Min: 10, Max: 60, Avg: 30},
+		"fallback": {Min: 5.5, Max: 5.5, Avg: 5.5},
+		"other":    {Min: 7, Max: 7, Avg: 7},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("stats for %d processors, want %d: %+v", len(got), len(want), got)
+	}
+	for proc, s := range want {
+		if got[proc] != s {
+			t.Errorf("%s: %+v, want %+v", proc, got[proc], s)
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
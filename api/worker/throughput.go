@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rinha-backend-golang/models"
+)
+
+const (
+	minThroughputBucket  = time.Second
+	maxThroughputBucket  = 24 * time.Hour
+	maxThroughputBuckets = 10000
+)
+
+// parseBucket validates the bucket query parameter (a Go duration such as
+// "1m" or "30s"), defaulting to one minute.
+func parseBucket(v string) (time.Duration, error) {
+	if v == "" {
+		return time.Minute, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bucket: %w", err)
+	}
+	if d < minThroughputBucket || d > maxThroughputBucket {
+		return 0, fmt.Errorf("bucket must be between %s and %s", minThroughputBucket, maxThroughputBucket)
+	}
+	if d%time.Second != 0 {
+		return 0, fmt.Errorf("bucket must be a whole number of seconds")
+	}
+	return d, nil
+}
+
+func (w *Worker) handleThroughput(wr http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(wr, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, err := parseTimeRange(r)
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bucket, err := parseBucket(r.URL.Query().Get("bucket"))
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from != nil && to != nil && to.Sub(*from)/bucket > maxThroughputBuckets {
+		http.Error(wr, "range too large for bucket size", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := summaryContext()
+	defer cancel()
+	rows, err := w.readDB.Query(ctx, `SELECT date_bin($3::interval, created_at, TIMESTAMPTZ 'epoch') AS bucket,
+            COUNT(*), COALESCE(SUM(amount),0)
+        FROM payments WHERE status = 'processed' AND `+rangeFilter+`
+        GROUP BY bucket ORDER BY bucket`, from, to, bucket)
+	if err != nil {
+		summaryQueryFailed(ctx, wr, "throughput", err)
+		return
+	}
+	defer rows.Close()
+
+	buckets := make([]models.ThroughputBucket, 0)
+	for rows.Next() {
+		var b models.ThroughputBucket
+		if err := rows.Scan(&b.Bucket, &b.TotalRequests, &b.TotalAmount); err != nil {
+			continue
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		summaryQueryFailed(ctx, wr, "throughput", err)
+		return
+	}
+
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(buckets)
+}
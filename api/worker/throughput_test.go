@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"rinha-backend-golang/models"
+)
+
+func TestParseBucket(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", time.Minute, false},
+		{"30s", 30 * time.Second, false},
+		{"1h", time.Hour, false},
+		{"24h", 24 * time.Hour, false},
+		{"500ms", 0, true},
+		{"25h", 0, true},
+		{"1.5s", 0, true},
+		{"often", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBucket(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseBucket(%q) = %s, %v; want %s, error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestThroughputBuckets(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	rows := []struct {
+		id, amount, status string
+		at                 time.Duration // after start
+	}{
+		{"a", "10", "processed", 5 * time.Second},
+		{"b", "20", "processed", 50 * time.Second},
+		{"c", "5", "processed", 70 * time.Second},
+		{"d", "99", "failed", 80 * time.Second},
+		{"e", "40", "processed", 3 * time.Minute},
+	}
+	for _, r := range rows {
+		if _, err := pool.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor, status, created_at) VALUES ($1,$2,'default',$3,$4)",
+			r.id, r.amount, r.status, start.Add(r.at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := newTestWorker(nil)
+	w.readDB = pool
+
+	rec := httptest.NewRecorder()
+	w.handleThroughput(rec, httptest.NewRequest(http.MethodGet,
+		"/throughput?bucket=1m&from=2025-07-01T12:00:00Z&to=2025-07-01T12:10:00Z", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var got []models.ThroughputBucket
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ThroughputBucket{
+		{Bucket: start, TotalRequests: 2, TotalAmount: 30},
+		{Bucket: start.Add(time.Minute), TotalRequests: 1, TotalAmount: 5},
+		{Bucket: start.Add(3 * time.Minute), TotalRequests: 1, TotalAmount: 40},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d buckets, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].Bucket.Equal(want[i].Bucket) || got[i].TotalRequests != want[i].TotalRequests || got[i].TotalAmount != want[i].TotalAmount {
+			t.Errorf("bucket %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
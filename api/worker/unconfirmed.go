@@ -0,0 +1,38 @@
+package worker
+
+import "sync"
+
+// unconfirmedCharges remembers, per payment, the processors whose charge
+// attempt timed out. The processor may still have charged it, so when a
+// later attempt there answers 422 (it already holds the correlationId) the
+// payment counts as charged rather than failed. Entries live until the
+// payment is recorded or dead-lettered.
+type unconfirmedCharges struct {
+	mu sync.Mutex
+	m  map[string]map[string]bool // correlation ID to processor names
+}
+
+func newUnconfirmedCharges() *unconfirmedCharges {
+	return &unconfirmedCharges{m: make(map[string]map[string]bool)}
+}
+
+func (u *unconfirmedCharges) add(correlationID, processor string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.m[correlationID] == nil {
+		u.m[correlationID] = make(map[string]bool, 2)
+	}
+	u.m[correlationID][processor] = true
+}
+
+func (u *unconfirmedCharges) has(correlationID, processor string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.m[correlationID][processor]
+}
+
+func (u *unconfirmedCharges) remove(correlationID string) {
+	u.mu.Lock()
+	delete(u.m, correlationID)
+	u.mu.Unlock()
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/models"
+)
+
+// handleVerify compares the local per-processor totals with the totals each
+// processor reports on its own summary endpoint (config.ProcessorSummaryPath),
+// which is what the final consistency check is scored on.
+func (w *Worker) handleVerify(wr http.ResponseWriter, r *http.Request) {
+	if !w.dbHealthy.Load() {
+		http.Error(wr, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	local, err := querySummary(ctx, w.db, nil, nil)
+	if err != nil {
+		http.Error(wr, "db error", http.StatusInternalServerError)
+		return
+	}
+	resp := models.VerifyResponse{
+		Default:  w.verifyProcessor(ctx, "default", config.ProcessorURL("default"), local.Default),
+		Fallback: w.verifyProcessor(ctx, "fallback", config.ProcessorURL("fallback"), local.Fallback),
+	}
+	resp.Consistent = resp.Default.Match && resp.Fallback.Match
+
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(resp)
+}
+
+func (w *Worker) verifyProcessor(ctx context.Context, name, url string, local models.Summary) models.VerifyResult {
+	result := models.VerifyResult{Local: local}
+	remote, err := w.fetchProcessorSummary(ctx, url)
+	if err != nil {
+		logging.Warnf("Worker: could not fetch %s processor summary: %v", name, err)
+		result.Error = err.Error()
+		return result
+	}
+	result.Processor = remote
+	result.RequestsDiff = local.TotalRequests - remote.TotalRequests
+	// Round to cents so float summation noise is not reported as a mismatch.
+	result.AmountDiff = math.Round((local.TotalAmount-remote.TotalAmount)*100) / 100
+	result.Match = result.RequestsDiff == 0 && result.AmountDiff == 0
+	return result
+}
+
+func (w *Worker) fetchProcessorSummary(ctx context.Context, url string) (*models.Summary, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url+config.ProcessorSummaryPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	config.SetOutboundHeaders(req.Header)
+	if config.ProcessorAdminToken != "" {
+		req.Header.Set("X-Rinha-Token", config.ProcessorAdminToken)
+	}
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-OK status %d", resp.StatusCode)
+	}
+	var s models.Summary
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+)
+
+// warmUpConnections opens config.WarmupConns pooled connections to each
+// processor by issuing that many concurrent health requests, so the first
+// payments do not pay for the handshakes. The answer does not matter (the
+// health endpoint rate-limits with 429), only that the connection is made
+// and returned to the idle pool, which is why bodies are drained.
+func (w *Worker) warmUpConnections() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	opened := map[string]int{}
+	for name, url := range map[string]string{"default": config.ProcessorURL("default"), "fallback": config.ProcessorURL("fallback")} {
+		if url == "" {
+			continue
+		}
+		for i := 0; i < config.WarmupConns; i++ {
+			wg.Add(1)
+			go func(name, url string) {
+				defer wg.Done()
+				req, err := http.NewRequestWithContext(ctx, config.ProcessorHealthMethods[name], url+"/payments/service-health", nil)
+				if err != nil {
+					return
+				}
+				config.SetOutboundHeaders(req.Header)
+				resp, err := w.httpClient.Do(req)
+				if err != nil {
+					logging.Debugf("Worker: warm-up request to %s failed: %v", name, err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				mu.Lock()
+				opened[name]++
+				mu.Unlock()
+			}(name, url)
+		}
+	}
+	wg.Wait()
+	logging.Infof("Worker: warmed up connections to processors: default=%d fallback=%d", opened["default"], opened["fallback"])
+}
@@ -1,277 +1,704 @@
 package worker
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"rinha-backend-golang/backoff"
 	"rinha-backend-golang/config"
+	"rinha-backend-golang/logging"
+	"rinha-backend-golang/middleware"
 	"rinha-backend-golang/models"
 )
 
 // Worker processes payment requests and interacts with external processors.
 type Worker struct {
 	httpClient      *http.Client
+	processors      ProcessorClient
 	db              *pgxpool.Pool
+	readDB          *pgxpool.Pool // summary and time-series reads, maybe a replica
 	defaultHealthy  atomic.Bool
 	fallbackHealthy atomic.Bool
+	healthCache     atomic.Pointer[healthReading]
+	defaultLatency  atomic.Int64 // last reported minResponseTime, ms
+	fallbackLatency atomic.Int64
+	defaultForced   atomic.Int32 // health forced through /sim/processor
+	fallbackForced  atomic.Int32
+	healthPolled    chan struct{} // closed once both processors were polled
+	leader          *healthLeader // health check lease, nil unless HEALTH_LEADER_ELECTION
+	dbHealthy       atomic.Bool
+	insertFailures  atomic.Int64 // consecutive failed payment inserts
+	retryBudgets    map[string]*retryBudget
+	seen            *bloomFilter  // nil when the bloom filter is disabled
+	inflight        chan struct{} // semaphore of MaxInflight slots, nil when unlimited
+	limiter         *rateLimiter  // global outbound rate limit, nil when unlimited
+	routed          routingCounts // first-choice processor per payment pass
+	weighted        weightedRoundRobin
+	rate            rateCounter // processed payments per second
+	callErrors      processorErrors
+	breakers        map[string]*circuitBreaker // nil unless BREAKER_FAILURES
+
+	// Processors that are up but answered the health poll slower than
+	// HEALTH_DEGRADED_MS; they are tried after one that is not.
+	defaultDegraded  atomic.Bool
+	fallbackDegraded atomic.Bool
+
+	// Payments being processed and payments waiting for a re-process pass,
+	// saved to payment_outbox if the worker shuts down before they finish.
+	active    *paymentSet
+	scheduled *paymentSet
+	stopping  atomic.Bool
+
+	// Processors whose charge of a payment timed out, see unconfirmedCharges.
+	unconfirmed *unconfirmedCharges
+
+	ordered *orderedQueues  // per-key FIFO queues, nil unless ORDERED_PROCESSING
+	events  *eventHub       // processed payments for /payments/stream
+	batcher *outcomeBatcher // batched processed writes, nil unless WORKER_BATCH_INSERTS
 }
 
 // NewWorker creates a new Worker instance.
 func NewWorker() *Worker {
 	w := &Worker{
 		httpClient: &http.Client{
-			Timeout: config.PaymentTimeout,
+			Timeout: max(config.PaymentTimeout, config.ProcessorAttemptTimeout),
 			Transport: &http.Transport{
+				DialContext:         (&net.Dialer{Timeout: config.DialTimeout}).DialContext,
+				TLSHandshakeTimeout: config.TLSHandshakeTimeout,
 				MaxIdleConns:        200,
 				MaxIdleConnsPerHost: 100,
 				IdleConnTimeout:     60 * time.Second,
 			},
 		},
-		db: config.PostgresPool,
+		db:     config.PostgresPool,
+		readDB: config.PostgresReadPool,
+		retryBudgets: map[string]*retryBudget{
+			"default":  newRetryBudget(config.RetryBudgetRatio, config.RetryBudgetTokens),
+			"fallback": newRetryBudget(config.RetryBudgetRatio, config.RetryBudgetTokens),
+		},
+		active:       newPaymentSet(),
+		unconfirmed:  newUnconfirmedCharges(),
+		scheduled:    newPaymentSet(),
+		events:       newEventHub(),
+		healthPolled: make(chan struct{}),
+	}
+	w.processors = &httpProcessorClient{client: w.httpClient}
+	// Health is unknown until the first poll.
+	w.setHealthUnknown("default")
+	w.setHealthUnknown("fallback")
+	w.dbHealthy.Store(w.db != nil)
+	if config.MaxInflight > 0 {
+		w.inflight = make(chan struct{}, config.MaxInflight)
+	}
+	if config.OrderedProcessing {
+		w.ordered = newOrderedQueues()
+	}
+	if config.BreakerFailures > 0 {
+		w.breakers = map[string]*circuitBreaker{
+			"default":  {name: "default"},
+			"fallback": {name: "fallback"},
+		}
+	}
+	if config.ProcessorRateLimit > 0 {
+		w.limiter = newRateLimiter(config.ProcessorRateLimit, config.ProcessorRateBurst, config.ProcessorRateMaxWait)
+	}
+	if w.db != nil {
+		if config.HealthLeaderElection {
+			w.leader = newHealthLeader(w.db)
+		}
+		if config.WorkerBatchInserts {
+			w.batcher = newOutcomeBatcher(w.db, func(ctx context.Context, req models.PaymentRequest) error {
+				return w.recordOutcome(ctx, req, models.StatusProcessed, nil)
+			}, w.committed, w.insertResult)
+		}
+	}
+	if config.DedupBloomBits > 0 {
+		w.seen = newBloomFilter(config.DedupBloomBits, config.DedupBloomHashes)
+		w.seedBloomFilter()
 	}
-	w.defaultHealthy.Store(true)
-	w.fallbackHealthy.Store(true)
 	return w
 }
 
+// seedBloomFilter loads the correlation IDs already persisted so the filter
+// never reports "absent" for a row that exists. The filter is only kept up to
+// date with this worker's own inserts, so it should be enabled only when the
+// worker is the sole writer of processed payments.
+func (w *Worker) seedBloomFilter() {
+	if w.db == nil {
+		return
+	}
+	rows, err := w.db.Query(context.Background(), "SELECT correlation_id FROM payments WHERE status = 'processed'")
+	if err != nil {
+		logging.Warnf("Worker: could not seed bloom filter, disabling it: %v", err)
+		w.seen = nil
+		return
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			continue
+		}
+		w.seen.add(id)
+		n++
+	}
+	logging.Infof("Worker: bloom filter seeded with %d correlation IDs", n)
+}
+
+// lookupProcessed reports whether the payment was already processed and, if
+// so, the amount it was recorded with. The bloom filter, when enabled, answers
+// the common not-a-duplicate case without a DB round-trip; a "maybe"
+// (including false positives) falls through to the authoritative table lookup.
+func (w *Worker) lookupProcessed(ctx context.Context, correlationID string) (bool, *models.Amount, error) {
+	if w.batcher != nil {
+		if amount, ok := w.batcher.lookup(correlationID); ok {
+			return true, &amount, nil
+		}
+	}
+	if w.seen != nil && !w.seen.mayContain(correlationID) {
+		return false, nil, nil
+	}
+	var text *string
+	err := w.db.QueryRow(ctx, "SELECT amount::text FROM payments WHERE correlation_id=$1 AND status = 'processed' LIMIT 1", correlationID).Scan(&text)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil, nil
+	}
+	if err != nil || text == nil {
+		return err == nil, nil, err
+	}
+	amount := models.Amount(*text)
+	return true, &amount, nil
+}
+
 // Start initializes the Worker and starts listening for requests.
 func (w *Worker) Start() {
-	go w.startHealthChecks()
-	http.HandleFunc("/process-payment", w.handleProcessPayment)
-	http.HandleFunc("/payments-summary", w.handlePaymentsSummary)
-	http.HandleFunc("/purge-payments", w.handlePurgePayments)
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
-
+	w.StartBackground()
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8081"
 	}
-	log.Printf("Worker starting on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	logging.Infof("Worker starting on port %s", port)
+	w.Serve(&http.Server{Addr: ":" + port, Handler: w.Handler()})
+}
+
+// StartBackground starts health checks and the other background loops
+// without serving HTTP, for when the worker is embedded in the gateway.
+func (w *Worker) StartBackground() {
+	if w.db != nil && config.PurgeOnStart {
+		w.purgeOnStart()
+	}
+	if config.WarmupConns > 0 {
+		w.warmUpConnections()
+	}
+	if config.DisableHealthChecks {
+		// Routing is purely optimistic, whatever the failure policy.
+		w.setHealthy("default", true)
+		w.setHealthy("fallback", true)
+		logging.Infof("Worker: health checks disabled; treating all processors as healthy")
+		close(w.healthPolled)
+	} else {
+		go w.startHealthChecks()
+		w.awaitFirstHealthPoll()
+	}
+	if config.ProcessorDNSTTL > 0 {
+		go w.startDNSRefresh()
+	}
+	if config.PartitionByDay {
+		go w.startPartitionMaintenance()
+	}
+	if w.db != nil && config.PaymentRetention > 0 {
+		go w.startPaymentPruner()
+	}
+	if config.ForwardMode == "pull" {
+		go w.startPuller()
+	}
+	if w.db != nil {
+		go w.startDBPinger()
+		w.drainOutbox()
+	}
+	if w.db != nil && config.SummarySnapshotInterval > 0 {
+		go w.startSummarySnapshots()
+	}
+}
+
+// Handler returns the worker's HTTP routes.
+func (w *Worker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	middleware.HandleFunc(mux, "/process-payment", w.handleProcessPayment)
+	middleware.HandleFunc(mux, "/payments-summary", w.handlePaymentsSummary)
+	middleware.HandleFunc(mux, "/payments-summary/hourly", w.handleHourlySummary)
+	middleware.HandleFunc(mux, "/purge-payments", w.handlePurgePayments)
+	middleware.HandleFunc(mux, "/throughput", w.handleThroughput)
+	middleware.HandleFunc(mux, "/payments/count", w.handlePaymentsCount)
+	// Not wrapped in a timeout: the stream is meant to stay open, and
+	// http.TimeoutHandler cannot flush.
+	mux.HandleFunc("/payments/stream", w.handlePaymentStream)
+	middleware.HandleFunc(mux, "/maintenance/vacuum", w.handleVacuum)
+	middleware.HandleFunc(mux, "/snapshots", w.handleSnapshots)
+	middleware.HandleFunc(mux, "/summary/snapshot-reset", w.handleSnapshotReset)
+	middleware.HandleFunc(mux, "/verify", w.handleVerify)
+	middleware.HandleFunc(mux, "/readyz", w.handleReadyz)
+	middleware.HandleFunc(mux, "/debug/inflight", w.handleInflight)
+	middleware.HandleFunc(mux, "/processor-errors", w.handleProcessorErrors)
+	middleware.HandleFunc(mux, "/status", w.handleStatus)
+	middleware.HandleFunc(mux, "/config/processors", w.handleProcessorURLs)
+	if config.EnableSimEndpoints {
+		logging.Warnf("Worker: simulation endpoints enabled, not for production")
+		middleware.HandleFunc(mux, "/sim/processor", w.handleSimProcessor)
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	return mux
 }
 
 func (w *Worker) handleProcessPayment(wr http.ResponseWriter, r *http.Request) {
-	log.Println("Worker received process-payment request")
+	logging.Debugf("Worker received process-payment request")
 	var req models.PaymentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Worker: Invalid request body: %v", err)
+		logging.Errorf("Worker: Invalid request body: %v", err)
 		http.Error(wr, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 	req.Timestamp = time.Now()
-	log.Printf("Worker processing payment: %s, Amount: %.2f", req.CorrelationID, req.Amount)
-	go w.processPayment(req)
+	deadline, err := models.ParseDeadline(r.Header.Get(models.DeadlineHeader), r.Header.Get(models.TimeoutHeader), req.Timestamp)
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !deadline.IsZero() && !req.Timestamp.Before(deadline) {
+		http.Error(wr, "Deadline exceeded", http.StatusRequestTimeout)
+		return
+	}
+	req.Deadline = deadline
+	req.PartitionKey = r.Header.Get(models.PartitionKeyHeader)
+	if !w.Submit(req) {
+		// Backpressure: let the gateway re-queue instead of piling up goroutines.
+		http.Error(wr, "Worker at capacity", http.StatusServiceUnavailable)
+		return
+	}
 	wr.WriteHeader(http.StatusOK)
 }
 
+// Submit starts processing a payment in the background, reporting false
+// without blocking when the worker is at capacity or has stopped charging
+// because Postgres is unreachable or payment inserts keep failing. It is what
+// /process-payment calls, and what the gateway calls directly in combined
+// mode; either way the gateway keeps the payment and offers it again.
+func (w *Worker) Submit(req models.PaymentRequest) bool {
+	if !w.dbHealthy.Load() || w.insertsFailing() {
+		return false
+	}
+	if !w.acquireSlot() {
+		return false
+	}
+	logging.Paymentf(req.CorrelationID, "Worker processing payment: %s, Amount: %s", req.CorrelationID, req.Amount)
+	if w.ordered != nil {
+		if key := orderingKey(req); key != "" {
+			// Tracked as scheduled until its turn, so a shutdown saves it.
+			w.scheduled.add(req)
+			if w.ordered.push(key, req) {
+				go w.runOrdered(key)
+			}
+			return true
+		}
+	}
+	go func() {
+		defer w.releaseSlot()
+		w.processPayment(req)
+	}()
+	return true
+}
+
+// acquireSlot takes an in-flight slot without blocking, reporting false when
+// config.MaxInflight payments are already being processed.
+func (w *Worker) acquireSlot() bool {
+	if w.inflight == nil {
+		return true
+	}
+	select {
+	case w.inflight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *Worker) releaseSlot() {
+	if w.inflight != nil {
+		<-w.inflight
+	}
+}
+
 func (w *Worker) processPayment(req models.PaymentRequest) {
+	w.active.add(req)
+	defer w.active.remove(req.CorrelationID)
 	ctx := context.Background()
+	start := time.Now()
+	var processorTime time.Duration
+	defer func() { w.logIfSlow(req, start, processorTime) }()
+
+	// The client deadline and config.ProcessorTotalTimeout bound processor
+	// calls only, all attempts of this pass together: once a processor has
+	// charged the payment it must still be recorded.
+	chargeCtx := ctx
+	if !req.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		chargeCtx, cancel = context.WithDeadline(chargeCtx, req.Deadline)
+		defer cancel()
+	}
+	if config.ProcessorTotalTimeout > 0 {
+		var cancel context.CancelFunc
+		chargeCtx, cancel = context.WithTimeout(chargeCtx, config.ProcessorTotalTimeout)
+		defer cancel()
+	}
+	charge := func(name, url string) error {
+		t := time.Now()
+		err := w.chargeWithRetries(chargeCtx, name, url, req)
+		processorTime += time.Since(t)
+		return err
+	}
+
+	// Without the database we can neither dedup nor record the payment, and
+	// while inserts fail it could be charged but not recorded. Either way
+	// try again later; the wait is not a failed pass, so it is not counted.
+	if !w.dbHealthy.Load() {
+		w.postpone(req, "Postgres unavailable")
+		return
+	}
+	if w.insertsFailing() {
+		w.postpone(req, "payment inserts failing")
+		return
+	}
 
 	// Check duplicate via payments table
+	lookupCtx, cancel := dbContext(ctx)
 	var exists bool
-	if err := w.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM payments WHERE correlation_id=$1)", req.CorrelationID).Scan(&exists); err != nil {
-		log.Printf("Worker: duplicate check error: %v", err)
+	var existingAmount *models.Amount
+	err := retrySerializable(lookupCtx, "duplicate check for payment "+req.CorrelationID, func() (err error) {
+		exists, existingAmount, err = w.lookupProcessed(lookupCtx, req.CorrelationID)
+		return err
+	})
+	cancel()
+	if err != nil {
+		// Nothing was charged yet, so another pass can run; it counts as
+		// one, so a database that keeps failing dead-letters the payment.
+		logging.Warnf("Worker: duplicate check for payment %s failed: %v", req.CorrelationID, err)
+		w.retryOrDeadLetter(req, fmt.Errorf("duplicate check: %w", err))
 		return
 	}
 	if exists {
-		log.Printf("Worker: Correlation ID %s already processed, skipping.", req.CorrelationID)
+		if existingAmount != nil && !existingAmount.Equal(req.Amount) {
+			conflictCtx, cancel := dbContext(ctx)
+			w.recordConflict(conflictCtx, req, *existingAmount)
+			cancel()
+			return
+		}
+		logging.Paymentf(req.CorrelationID, "Worker: Correlation ID %s already processed, skipping.", req.CorrelationID)
+		return
+	}
+
+	if config.DryRun {
+		req.Processor = "dry-run"
+		w.recordPayment(ctx, req)
+		logging.Paymentf(req.CorrelationID, "Worker: Dry-run payment %s recorded without calling a processor.", req.CorrelationID)
 		return
 	}
 
-	isDefaultHealthy := w.defaultHealthy.Load()
-	isFallbackHealthy := w.fallbackHealthy.Load()
+	isDefaultHealthy, isFallbackHealthy := w.processorHealth()
+	if w.breakers != nil {
+		now := time.Now()
+		isDefaultHealthy = isDefaultHealthy && w.breakerAllows("default", now)
+		isFallbackHealthy = isFallbackHealthy && w.breakerAllows("fallback", now)
+	}
 
-	log.Printf("Worker: Health status - Default: %t, Fallback: %t", isDefaultHealthy, isFallbackHealthy)
+	logging.Paymentf(req.CorrelationID, "Worker: Health status for payment %s - Default: %t, Fallback: %t", req.CorrelationID, isDefaultHealthy, isFallbackHealthy)
 
-	if isDefaultHealthy {
-		log.Printf("Worker: Attempting to call default processor for payment %s", req.CorrelationID)
-		if w.callProcessor(config.DefaultProcessorURL, req) {
-			req.Processor = "default"
-			if _, err := w.db.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor) VALUES ($1,$2,$3)", req.CorrelationID, req.Amount, req.Processor); err != nil {
-				log.Printf("Worker: Error inserting payment: %v", err)
-			}
-			log.Printf("Worker: Successfully processed payment %s with default processor and updated Postgres.", req.CorrelationID)
+	lastErr := errNoHealthyProcessor
+
+	if config.HedgeAfter > 0 && isDefaultHealthy && isFallbackHealthy {
+		t := time.Now()
+		name, err := w.chargeHedged(chargeCtx, req)
+		processorTime += time.Since(t)
+		if err == nil {
+			req.Processor = name
+			w.recordPayment(ctx, req)
+			logging.Paymentf(req.CorrelationID, "Worker: Successfully processed payment %s with %s processor and updated Postgres.", req.CorrelationID, name)
 			return
-		} else {
-			log.Printf("Worker: Failed to process payment %s with default processor.", req.CorrelationID)
 		}
+		logging.Errorf("Worker: No healthy processor found or payment %s could not be processed.", req.CorrelationID)
+		w.retryOrDeadLetter(req, err)
+		return
 	}
 
-	if isFallbackHealthy {
-		log.Printf("Worker: Attempting to call fallback processor for payment %s", req.CorrelationID)
-		if w.callProcessor(config.FallbackProcessorURL, req) {
-			req.Processor = "fallback"
-			if _, err := w.db.Exec(ctx, "INSERT INTO payments (correlation_id, amount, processor) VALUES ($1,$2,$3)", req.CorrelationID, req.Amount, req.Processor); err != nil {
-				log.Printf("Worker: Error inserting payment: %v", err)
-			}
-			log.Printf("Worker: Successfully processed payment %s with fallback processor and updated Postgres.", req.CorrelationID)
+	for i, target := range w.selectProcessors(req, isDefaultHealthy, isFallbackHealthy) {
+		if i > 0 && !canFallBack(lastErr) {
+			break
+		}
+		logging.Paymentf(req.CorrelationID, "Worker: Attempting to call %s processor for payment %s", target.name, req.CorrelationID)
+		err := charge(target.name, target.url)
+		if err == nil {
+			req.Processor = target.name
+			w.recordPayment(ctx, req)
+			logging.Paymentf(req.CorrelationID, "Worker: Successfully processed payment %s with %s processor and updated Postgres.", req.CorrelationID, target.name)
 			return
-		} else {
-			log.Printf("Worker: Failed to process payment %s with fallback processor.", req.CorrelationID)
 		}
+		lastErr = err
+		logging.Paymentf(req.CorrelationID, "Worker: Failed to process payment %s with %s processor.", req.CorrelationID, target.name)
 	}
 
-	log.Printf("Worker: No healthy processor found or payment %s could not be processed.", req.CorrelationID)
+	logging.Errorf("Worker: No healthy processor found or payment %s could not be processed.", req.CorrelationID)
+	w.retryOrDeadLetter(req, lastErr)
 }
 
-func (w *Worker) callProcessor(url string, req models.PaymentRequest) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), config.PaymentTimeout)
-	defer cancel()
-
-	var err error // Declare err once
+// postpone schedules another pass of req without counting this one, for
+// when the database cannot be used yet.
+func (w *Worker) postpone(req models.PaymentRequest, reason string) {
+	delay := reprocessDelay(req.Attempts + 1)
+	logging.Warnf("Worker: %s, re-queueing payment %s in %s", reason, req.CorrelationID, delay)
+	w.scheduled.add(req)
+	time.AfterFunc(delay, func() { w.reprocess(req) })
+}
 
-	reqBody, err := json.Marshal(req)
-	if err != nil {
-		log.Printf("Worker: Error marshalling payment request %s for processor %s: %v", req.CorrelationID, url, err)
-		return false
-	}
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url+"/payments", bytes.NewReader(reqBody))
-	if err != nil {
-		log.Printf("Worker: Error creating request for payment %s to processor %s: %v", req.CorrelationID, url, err)
-		return false
+// maxRecordAttempts bounds how often recordPayment retries a write that
+// timed out.
+const maxRecordAttempts = 5
+
+// recordPayment persists a successfully processed payment, directly or
+// through the batcher. The payment is already charged, so a write that times
+// out is retried with backoff rather than re-queueing the whole pass, which
+// would charge it again. With config.DedupUpsert a payment another pass
+// recorded first is not counted again.
+func (w *Worker) recordPayment(ctx context.Context, req models.PaymentRequest) {
+	if w.batcher != nil && w.batcher.add(req) {
+		return
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	resp, err := w.httpClient.Do(httpReq)
-	if err != nil {
-		log.Printf("Worker: Error calling processor %s for payment %s: %v", url, req.CorrelationID, err)
-		return false
+	for attempt := 1; ; attempt++ {
+		writeCtx, cancel := dbContext(ctx)
+		recorded := true
+		err := retrySerializable(writeCtx, "recording payment "+req.CorrelationID, func() (err error) {
+			if config.DedupUpsert {
+				recorded, err = w.upsertProcessed(writeCtx, req)
+				return err
+			}
+			return w.recordOutcome(writeCtx, req, models.StatusProcessed, nil)
+		})
+		cancel()
+		if err == nil && !recorded {
+			w.insertResult(nil)
+			logging.Warnf("Worker: payment %s was already recorded by another pass, not counting it again", req.CorrelationID)
+			return
+		}
+		if err == nil {
+			w.insertResult(nil)
+			w.committed(req)
+			return
+		}
+		if !errors.Is(err, context.DeadlineExceeded) || attempt == maxRecordAttempts {
+			w.insertResult(err)
+			logging.Errorf("Worker: Error inserting payment: %v", err)
+			return
+		}
+		logging.Warnf("Worker: recording payment %s timed out (attempt %d), retrying", req.CorrelationID, attempt)
+		time.Sleep(reprocessDelay(attempt))
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Worker: Processor %s returned non-OK status %d for payment %s", url, resp.StatusCode, req.CorrelationID)
-		return false
+// dbContext bounds one per-payment query by config.DBQueryTimeout.
+func dbContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if config.DBQueryTimeout <= 0 {
+		return context.WithCancel(parent)
 	}
+	return context.WithTimeout(parent, config.DBQueryTimeout)
+}
 
-	// Decode response body to check for success message
-	var processorResp struct {
-		Message string `json:"message"`
-	}
-	if err = json.NewDecoder(resp.Body).Decode(&processorResp); err != nil {
-		log.Printf("Worker: Error decoding processor response for %s: %v", url, err)
-		return false
+// committed runs once a processed payment's row is written.
+func (w *Worker) committed(req models.PaymentRequest) {
+	w.unconfirmed.remove(req.CorrelationID)
+	w.rate.add(time.Now())
+	if w.seen != nil {
+		w.seen.add(req.CorrelationID)
 	}
+	w.events.publish(models.PaymentEvent{
+		CorrelationID: req.CorrelationID,
+		Processor:     req.Processor,
+		Amount:        req.Amount,
+		ProcessedAt:   time.Now(),
+	})
+}
 
-	if processorResp.Message != "payment processed successfully" {
-		log.Printf("Worker: Processor %s returned unexpected message '%s' for payment %s", url, processorResp.Message, req.CorrelationID)
-		return false
+// logIfSlow warns when a payment took longer than config.SlowPaymentThreshold
+// from receipt to completion, breaking the time down by stage.
+func (w *Worker) logIfSlow(req models.PaymentRequest, start time.Time, processorTime time.Duration) {
+	if config.SlowPaymentThreshold <= 0 {
+		return
 	}
-
-	log.Printf("Worker: Successfully processed payment %s with processor %s", req.CorrelationID, url)
-	return true
+	end := time.Now()
+	total := end.Sub(req.Timestamp)
+	if total < config.SlowPaymentThreshold {
+		return
+	}
+	logging.Warnf("Worker: WARN slow payment %s: total=%s queue_wait=%s processor=%s other=%s",
+		req.CorrelationID, total, start.Sub(req.Timestamp), processorTime, end.Sub(start)-processorTime)
 }
 
-func (w *Worker) handlePaymentsSummary(wr http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+// chargeHedged calls the default processor and, if it has not succeeded within
+// config.HedgeAfter (or fails sooner), the fallback in parallel. The first
+// success wins and the other call is cancelled. A cancelled call may still
+// have been charged on the processor side, so hedging trades some risk of a
+// double charge for lower tail latency.
+func (w *Worker) chargeHedged(parent context.Context, req models.PaymentRequest) (string, error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
 
-	rows, err := w.db.Query(ctx, "SELECT processor, COUNT(*), COALESCE(SUM(amount),0) FROM payments GROUP BY processor")
-	if err != nil {
-		http.Error(wr, "db error", http.StatusInternalServerError)
-		return
+	type result struct {
+		name string
+		err  error
 	}
-	var defaultSummary, fallbackSummary models.Summary
-	for rows.Next() {
-		var proc string
-		var cnt int64
-		var amt float64
-		if err := rows.Scan(&proc, &cnt, &amt); err != nil {
-			continue
-		}
-		if proc == "default" {
-			defaultSummary = models.Summary{TotalRequests: cnt, TotalAmount: amt}
-		} else if proc == "fallback" {
-			fallbackSummary = models.Summary{TotalRequests: cnt, TotalAmount: amt}
-		}
+	results := make(chan result, 2)
+	launch := func(name, url string) {
+		go func() { results <- result{name, w.chargeWithRetries(ctx, name, url, req)} }()
 	}
 
-	summary := models.PaymentSummaryResponse{
-		Default:  defaultSummary,
-		Fallback: fallbackSummary,
+	launch("default", config.ProcessorURL("default"))
+	pending, hedged := 1, false
+	hedge := func() {
+		if !hedged {
+			hedged = true
+			pending++
+			logging.Paymentf(req.CorrelationID, "Worker: Hedging payment %s with fallback processor", req.CorrelationID)
+			launch("fallback", config.ProcessorURL("fallback"))
+		}
 	}
-
-	wr.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(wr).Encode(summary)
+	timer := time.NewTimer(config.HedgeAfter)
+	defer timer.Stop()
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			hedge()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.name, nil
+			}
+			lastErr = r.err
+			if !canFallBack(r.err) {
+				return "", r.err
+			}
+			hedge()
+		}
+	}
+	return "", lastErr
 }
 
-func (w *Worker) handlePurgePayments(wr http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
-	if _, err := w.db.Exec(ctx, "TRUNCATE payments"); err != nil {
-		log.Printf("Worker: purge error: %v", err)
-	}
-	// Optionally, clear all processed IDs if needed, but be careful with large datasets
-	// For now, we assume correlation IDs are unique per test run and don't need explicit purging
+// Backoff between retries of one processor call.
+const (
+	processorRetryBase = 10 * time.Millisecond
+	processorRetryMax  = 250 * time.Millisecond
+)
 
-	wr.WriteHeader(http.StatusOK)
+// chargeWithRetries calls a processor, retrying failed attempts up to
+// config.ProcessorRetries times while its retry budget allows it, with
+// backoff between attempts.
+func (w *Worker) chargeWithRetries(ctx context.Context, name, url string, req models.PaymentRequest) error {
+	budget := w.retryBudgets[name]
+	budget.onRequest()
+	for attempt := 0; ; attempt++ {
+		err := w.callProcessor(ctx, name, url, req)
+		if err == nil {
+			return nil
+		}
+		if attempt >= config.ProcessorRetries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+		if !budget.tryRetry() {
+			logging.Warnf("Worker: Retry budget for %s exhausted, not retrying payment %s", name, req.CorrelationID)
+			return err
+		}
+		delay := backoff.Delay(attempt+1, processorRetryBase, processorRetryMax)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// The retry could not start before the deadline.
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
 }
 
-func (w *Worker) startHealthChecks() {
-	ticker := time.NewTicker(config.HealthCheckInterval)
-	defer ticker.Stop()
-	for range ticker.C {
-		w.checkProcessorHealth("default", config.DefaultProcessorURL)
-		w.checkProcessorHealth("fallback", config.FallbackProcessorURL)
+// callProcessor makes one charge attempt, returning nil on success.
+func (w *Worker) callProcessor(ctx context.Context, name, url string, req models.PaymentRequest) error {
+	if w.limiter != nil {
+		if err := w.limiter.wait(ctx); err != nil {
+			logging.Warnf("Worker: Not calling processor %s for payment %s: %v", name, req.CorrelationID, err)
+			w.callErrors.add(name, err)
+			return err
+		}
+	}
+	ok, err := w.processors.Charge(ctx, name, url, req)
+	if err == nil && !ok {
+		err = errPaymentDeclined
+	}
+	if errors.Is(err, ErrProcessorDuplicate) && w.unconfirmed.has(req.CorrelationID, name) {
+		// An earlier attempt here timed out after the processor took it.
+		logging.Warnf("Worker: processor %s already holds payment %s after a timed-out attempt, counting it as charged", name, req.CorrelationID)
+		err = nil
+	}
+	if errors.Is(err, ErrProcessorTimeout) {
+		w.unconfirmed.add(req.CorrelationID, name)
+	}
+	if b := w.breakers[name]; b != nil {
+		b.record(err, time.Now())
 	}
+	if err != nil {
+		logging.Errorf("Worker: Error calling processor %s for payment %s: %v", url, req.CorrelationID, err)
+		w.callErrors.add(name, err)
+		return err
+	}
+	logging.Paymentf(req.CorrelationID, "Worker: Successfully processed payment %s with processor %s", req.CorrelationID, url)
+	return nil
 }
 
-func (w *Worker) checkProcessorHealth(name, url string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	log.Printf("Worker: Checking health for %s at %s/payments/service-health", name, url)
-	req, err := http.NewRequestWithContext(ctx, "GET", url+"/payments/service-health", nil)
+// handlePaymentsCount returns just the number of persisted payments, optionally
+// within from/to, as a cheap alternative to the grouped summary.
+func (w *Worker) handlePaymentsCount(wr http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r)
 	if err != nil {
-		log.Printf("Worker: Error creating health check request for %s: %v", name, err)
-		if name == "default" {
-			w.defaultHealthy.Store(false)
-		} else {
-			w.fallbackHealthy.Store(false)
-		}
+		http.Error(wr, err.Error(), http.StatusBadRequest)
 		return
 	}
-	resp, err := w.httpClient.Do(req)
-	if err != nil {
-		log.Printf("Worker: Error calling health check for %s: %v", name, err)
-		if name == "default" {
-			w.defaultHealthy.Store(false)
-		} else {
-			w.fallbackHealthy.Store(false)
-		}
+	ctx, cancel := summaryContext()
+	defer cancel()
+	var resp models.PaymentCountResponse
+	if err := w.readDB.QueryRow(ctx, "SELECT count(*) FROM payments WHERE status = 'processed' AND "+rangeFilter, from, to).Scan(&resp.Count); err != nil {
+		summaryQueryFailed(ctx, wr, "count", err)
 		return
 	}
-	defer resp.Body.Close()
-
-	log.Printf("Worker: Health check for %s returned status: %d", name, resp.StatusCode)
+	wr.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(wr).Encode(resp)
+}
 
-	if resp.StatusCode == 200 {
-		var healthResp models.ServiceHealthResponse
-		if err := json.NewDecoder(resp.Body).Decode(&healthResp); err == nil {
-			log.Printf("Worker: Health check for %s - Failing: %t", name, healthResp.Failing)
-			if name == "default" {
-				w.defaultHealthy.Store(!healthResp.Failing)
-			} else {
-				w.fallbackHealthy.Store(!healthResp.Failing)
-			}
-		} else {
-			log.Printf("Worker: Error decoding health check response for %s: %v", name, err)
-			if name == "default" {
-				w.defaultHealthy.Store(false)
-			} else {
-				w.fallbackHealthy.Store(false)
-			}
-		}
-	} else {
-		log.Printf("Worker: Health check for %s failed with non-200 status: %d", name, resp.StatusCode)
-		if name == "default" {
-			w.defaultHealthy.Store(false)
-		} else {
-			w.fallbackHealthy.Store(false)
-		}
+func (w *Worker) handlePurgePayments(wr http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	if _, err := w.db.Exec(ctx, "TRUNCATE payments, payments_pruned_totals"); err != nil {
+		logging.Errorf("Worker: purge error: %v", err)
 	}
+	// Optionally, clear all processed IDs if needed, but be careful with large datasets
+	// For now, we assume correlation IDs are unique per test run and don't need explicit purging
+
+	wr.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestAcquireSlotBurst fires a burst of payments at a worker limited to
+// three in flight and checks the limit holds.
+func TestAcquireSlotBurst(t *testing.T) {
+	const limit, burst = 3, 50
+	w := &Worker{inflight: make(chan struct{}, limit)}
+
+	var running, peak, refused atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !w.acquireSlot() {
+				refused.Add(1)
+				return
+			}
+			defer w.releaseSlot()
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p > limit {
+		t.Errorf("%d payments in flight, limit %d", p, limit)
+	}
+	if refused.Load() == 0 {
+		t.Error("no payment refused in a burst over the limit")
+	}
+	if n := len(w.inflight); n != 0 {
+		t.Errorf("%d slots still held after the burst", n)
+	}
+}
+
+func TestProcessPaymentAtCapacity(t *testing.T) {
+	w := &Worker{inflight: make(chan struct{}, 1)}
+	w.dbHealthy.Store(true)
+	w.inflight <- struct{}{}
+
+	rec := httptest.NewRecorder()
+	w.handleProcessPayment(rec, httptest.NewRequest(http.MethodPost, "/process-payment",
+		strings.NewReader(`{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":10}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d at capacity, want 503", rec.Code)
+	}
+}